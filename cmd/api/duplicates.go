@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"greenlight.gustavosantos.net/internal/data"
+	"greenlight.gustavosantos.net/internal/validator"
+)
+
+var mergeableMovieFields = []string{
+	"title",
+	"year",
+	"runtime",
+	"genres",
+	"overview",
+	"original_language",
+	"releases",
+	"external_ids",
+}
+
+func (app *application) listDuplicateMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Threshold float64
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.Threshold = app.readFloat(qs, "threshold", app.config.search.duplicateThreshold, v)
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "id"
+	input.Filters.SortSafelist = []string{"id"}
+	v.Check(input.Threshold > 0 && input.Threshold <= 1, "threshold", "must be between 0 and 1")
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	duplicates, metadata, err := app.models.Movies.Duplicates(input.Threshold, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"duplicates": duplicates, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) mergeMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		SourceID int64    `json:"source_id"`
+		TargetID int64    `json:"target_id"`
+		Keep     []string `json:"keep"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	v.Check(input.SourceID > 0, "source_id", "must be provided")
+	v.Check(input.TargetID > 0, "target_id", "must be provided")
+	v.Check(input.SourceID != input.TargetID, "target_id", "must be different from source_id")
+	for _, field := range input.Keep {
+		v.Check(validator.PermittedValue(field, mergeableMovieFields...), "keep", "invalid field "+field)
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	source, err := app.models.Movies.Get(input.SourceID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	target, err := app.models.Movies.Get(input.TargetID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	for _, field := range input.Keep {
+		switch field {
+		case "title":
+			target.Title = source.Title
+		case "year":
+			target.Year = source.Year
+		case "runtime":
+			target.Runtime = source.Runtime
+		case "genres":
+			target.Genres = source.Genres
+		case "overview":
+			target.Overview = source.Overview
+		case "original_language":
+			target.OriginalLanguage = source.OriginalLanguage
+		case "releases":
+			target.Releases = source.Releases
+		case "external_ids":
+			target.ExternalIDs = source.ExternalIDs
+		}
+	}
+	genres, lookupErr := app.models.Genres.Lookup()
+	if lookupErr != nil {
+		app.serverErrorResponse(w, r, lookupErr)
+		return
+	}
+	if data.ValidateMovie(v, target, genres); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	discarded, err := app.models.Movies.Merge(source.ID, target)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.deleteStoredImages(discarded...)
+	app.similar.clear()
+	err = app.attachImages(target)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": target, "merged_id": source.ID}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
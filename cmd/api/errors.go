@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"net/http"
+
+	"greenlight.gustavosantos.net/internal/data"
 )
 
 func (app *application) logError(r *http.Request, err error) {
@@ -51,6 +53,18 @@ func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Requ
 	app.errorResponse(w, r, http.StatusConflict, message)
 }
 
+func (app *application) possibleDuplicatesResponse(w http.ResponseWriter, r *http.Request, duplicates []*data.PossibleDuplicate) {
+	env := envelope{
+		"error":      "possible duplicate movies found, resubmit with force=true to create anyway",
+		"duplicates": duplicates,
+	}
+	err := app.writeJSON(w, http.StatusConflict, env, nil)
+	if err != nil {
+		app.logError(r, err)
+		w.WriteHeader(500)
+	}
+}
+
 func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
 	message := "rate limit exceeded"
 	app.errorResponse(w, r, http.StatusConflict, message)
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.gustavosantos.net/internal/data"
+)
+
+const (
+	eventStreamHeartbeat    = 15 * time.Second
+	eventStreamWriteTimeout = 10 * time.Second
+	eventStreamRetry        = 5 * time.Second
+	eventStreamBuffer       = 64
+	eventBacklogPageSize    = 500
+	eventPollInterval       = time.Second
+)
+
+type movieEventHub struct {
+	mu      sync.Mutex
+	clients map[chan *data.MovieEvent]struct{}
+	closed  bool
+}
+
+func newMovieEventHub() *movieEventHub {
+	return &movieEventHub{clients: make(map[chan *data.MovieEvent]struct{})}
+}
+
+func (h *movieEventHub) subscribe() (<-chan *data.MovieEvent, func(), bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, nil, false
+	}
+	ch := make(chan *data.MovieEvent, eventStreamBuffer)
+	h.clients[ch] = struct{}{}
+	unsubscribe := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.clients[ch]; ok {
+			delete(h.clients, ch)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe, true
+}
+
+func (h *movieEventHub) broadcast(event *data.MovieEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.clients {
+		select {
+		case ch <- event:
+		default:
+			delete(h.clients, ch)
+			close(ch)
+		}
+	}
+}
+
+func (h *movieEventHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for ch := range h.clients {
+		delete(h.clients, ch)
+		close(ch)
+	}
+}
+
+func (app *application) startNotificationListener(ctx context.Context) {
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		defer app.events.close()
+		defer app.presence.close()
+		err := app.listenNotifications(ctx)
+		if err != nil {
+			app.logger.Error(err.Error(), "job", "notifications")
+		}
+	}()
+}
+
+func (app *application) listenNotifications(ctx context.Context) error {
+	listener := pq.NewListener(app.config.db.dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			app.logger.Error(err.Error(), "job", "notifications")
+		}
+	})
+	defer listener.Close()
+	for _, channel := range []string{data.MovieEventChannel, data.MoviePresenceChannel} {
+		err := listener.Listen(channel)
+		if err != nil {
+			return err
+		}
+	}
+	cursor := app.pollMovieEvents(ctx, data.MovieEventCursor{})
+	poll := time.NewTicker(eventPollInterval)
+	defer poll.Stop()
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+	presence := time.NewTicker(presenceHeartbeat)
+	defer presence.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ping.C:
+			go listener.Ping()
+		case <-presence.C:
+			app.presence.heartbeat(ctx)
+		case <-poll.C:
+			cursor = app.pollMovieEvents(ctx, cursor)
+		case n := <-listener.Notify:
+			switch {
+			case n == nil:
+				cursor = app.pollMovieEvents(ctx, cursor)
+				app.presence.heartbeat(ctx)
+			case n.Channel == data.MovieEventChannel:
+				cursor = app.pollMovieEvents(ctx, cursor)
+			case n.Channel == data.MoviePresenceChannel:
+				app.presence.receive(ctx, []byte(n.Extra))
+			}
+		}
+	}
+}
+
+func (app *application) pollMovieEvents(ctx context.Context, cursor data.MovieEventCursor) data.MovieEventCursor {
+	if cursor == (data.MovieEventCursor{}) {
+		head, err := app.models.MovieEvents.Head(ctx)
+		if err != nil {
+			app.logger.Error(err.Error(), "job", "notifications")
+		}
+		return head
+	}
+	for {
+		events, err := app.models.MovieEvents.Since(ctx, cursor, eventBacklogPageSize)
+		if err != nil {
+			app.logger.Error(err.Error(), "job", "notifications")
+			return cursor
+		}
+		if len(events) > 0 {
+			app.similar.clear()
+		}
+		for _, event := range events {
+			app.events.broadcast(event)
+			cursor = event.Cursor
+		}
+		if len(events) < eventBacklogPageSize {
+			return cursor
+		}
+	}
+}
+
+func writeMovieEvent(w http.ResponseWriter, event *data.MovieEvent) error {
+	js, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: movie.%s\ndata: %s\n\n", event.Cursor, event.Operation, js)
+	return err
+}
+
+func (app *application) movieEventsHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		lastCursor data.MovieEventCursor
+		resume     bool
+	)
+	if s := r.Header.Get("Last-Event-ID"); s != "" {
+		cursor, err := data.ParseMovieEventCursor(s)
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("invalid Last-Event-ID header"))
+			return
+		}
+		lastCursor, resume = cursor, true
+	}
+	events, unsubscribe, ok := app.events.subscribe()
+	if !ok {
+		app.errorResponse(w, r, http.StatusServiceUnavailable, "the server is shutting down, please retry later")
+		return
+	}
+	defer unsubscribe()
+	rc := http.NewResponseController(w)
+	send := func(fn func() error) error {
+		err := rc.SetWriteDeadline(time.Now().Add(eventStreamWriteTimeout))
+		if err != nil {
+			return err
+		}
+		err = fn()
+		if err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	err := send(func() error {
+		w.WriteHeader(http.StatusOK)
+		_, err := fmt.Fprintf(w, "retry: %d\n\n", eventStreamRetry.Milliseconds())
+		return err
+	})
+	if err != nil {
+		app.logger.Error(err.Error(), "stream", "movies")
+		return
+	}
+	for resume {
+		backlog, err := app.models.MovieEvents.Since(r.Context(), lastCursor, eventBacklogPageSize)
+		if err != nil {
+			app.logger.Error(err.Error(), "stream", "movies")
+			return
+		}
+		for _, event := range backlog {
+			err = send(func() error { return writeMovieEvent(w, event) })
+			if err != nil {
+				return
+			}
+			lastCursor = event.Cursor
+		}
+		if len(backlog) < eventBacklogPageSize {
+			break
+		}
+	}
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			err = send(func() error {
+				_, err := fmt.Fprint(w, ": heartbeat\n\n")
+				return err
+			})
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if !event.Cursor.After(lastCursor) {
+				continue
+			}
+			lastCursor = event.Cursor
+			err = send(func() error { return writeMovieEvent(w, event) })
+		}
+		if err != nil {
+			return
+		}
+	}
+}
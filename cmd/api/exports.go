@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"greenlight.gustavosantos.net/internal/data"
+	"greenlight.gustavosantos.net/internal/validator"
+)
+
+type movieExportWriter interface {
+	begin() error
+	write(movie *data.Movie) error
+	end() error
+}
+
+type csvMovieExportWriter struct {
+	writer *csv.Writer
+}
+
+func (cw *csvMovieExportWriter) begin() error {
+	return cw.writer.Write([]string{"id", "title", "year", "runtime", "genres", "version"})
+}
+
+func (cw *csvMovieExportWriter) write(movie *data.Movie) error {
+	return cw.writer.Write([]string{
+		strconv.FormatInt(movie.ID, 10),
+		movie.Title,
+		strconv.Itoa(int(movie.Year)),
+		strconv.Itoa(int(movie.Runtime)),
+		strings.Join(movie.Genres, ","),
+		strconv.Itoa(int(movie.Version)),
+	})
+}
+
+func (cw *csvMovieExportWriter) end() error {
+	cw.writer.Flush()
+	return cw.writer.Error()
+}
+
+type ndjsonMovieExportWriter struct {
+	writer *bufio.Writer
+	enc    *json.Encoder
+}
+
+func (nw *ndjsonMovieExportWriter) begin() error {
+	return nil
+}
+
+func (nw *ndjsonMovieExportWriter) write(movie *data.Movie) error {
+	return nw.enc.Encode(movie)
+}
+
+func (nw *ndjsonMovieExportWriter) end() error {
+	return nw.writer.Flush()
+}
+
+type jsonMovieExportWriter struct {
+	writer *bufio.Writer
+	count  int
+}
+
+func (jw *jsonMovieExportWriter) begin() error {
+	_, err := jw.writer.WriteString("{\"movies\":[")
+	return err
+}
+
+func (jw *jsonMovieExportWriter) write(movie *data.Movie) error {
+	js, err := json.Marshal(movie)
+	if err != nil {
+		return err
+	}
+	if jw.count > 0 {
+		if commaErr := jw.writer.WriteByte(','); commaErr != nil {
+			return commaErr
+		}
+	}
+	jw.count++
+	_, err = jw.writer.Write(js)
+	return err
+}
+
+func (jw *jsonMovieExportWriter) end() error {
+	_, err := jw.writer.WriteString("]}\n")
+	if err != nil {
+		return err
+	}
+	return jw.writer.Flush()
+}
+
+func (app *application) exportMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.MovieCriteria
+		Format string
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.MovieCriteria = app.readMovieCriteria(qs, v)
+	input.Format = app.readString(qs, "format", "json")
+	data.ValidateMovieCriteria(v, input.MovieCriteria)
+	if v.Check(validator.PermittedValue(input.Format, "csv", "ndjson", "json"), "format", "invalid format value"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	buf := bufio.NewWriter(w)
+	var (
+		exporter    movieExportWriter
+		contentType string
+	)
+	switch input.Format {
+	case "csv":
+		exporter = &csvMovieExportWriter{writer: csv.NewWriter(w)}
+		contentType = "text/csv"
+	case "ndjson":
+		exporter = &ndjsonMovieExportWriter{writer: buf, enc: json.NewEncoder(buf)}
+		contentType = "application/x-ndjson"
+	default:
+		exporter = &jsonMovieExportWriter{writer: buf}
+		contentType = "application/json"
+	}
+	filename := fmt.Sprintf("movies-%s.%s", time.Now().UTC().Format("20060102T150405Z"), input.Format)
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(5 * time.Minute))
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	err := exporter.begin()
+	if err == nil {
+		err = app.models.Movies.Stream(input.MovieCriteria, exporter.write)
+	}
+	if err == nil {
+		err = exporter.end()
+	}
+	if err != nil {
+		app.logError(r, err)
+	}
+}
@@ -1,16 +1,20 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
+	"greenlight.gustavosantos.net/internal/data"
 	"greenlight.gustavosantos.net/internal/validator"
 )
 
@@ -109,15 +113,166 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	return i
 }
 
-func (app *application) background(fn func()) {
+func (app *application) readFloat(qs url.Values, key string, defaultValue float64, v *validator.Validator) float64 {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		v.AddError(key, "must be a decimal value")
+	}
+	return f
+}
+
+func (app *application) readInt64CSV(qs url.Values, key string, v *validator.Validator) []int64 {
+	values := []int64{}
+	for _, s := range app.readCSV(qs, key, []string{}) {
+		i, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			v.AddError(key, "must be a comma-separated list of integer values")
+			return nil
+		}
+		values = append(values, i)
+	}
+	return values
+}
+
+func (app *application) readTime(qs url.Values, key string, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+	if s == "" {
+		return time.Time{}
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t
+		}
+	}
+	v.AddError(key, "must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+	return time.Time{}
+}
+
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+	}
+	return b
+}
+
+func (app *application) paginationLinks(r *http.Request, filters data.Filters, metadata data.Metadata) string {
+	link := func(rel string, set map[string]string) string {
+		qs := r.URL.Query()
+		for _, key := range []string{"page", "cursor"} {
+			qs.Del(key)
+		}
+		for key, value := range set {
+			qs.Set(key, value)
+		}
+		return fmt.Sprintf("<%s?%s>; rel=%q", r.URL.Path, qs.Encode(), rel)
+	}
+	var links []string
+	if filters.CursorMode {
+		links = append(links, link("first", nil))
+		if metadata.PrevCursor != "" {
+			links = append(links, link("prev", map[string]string{"cursor": metadata.PrevCursor}))
+		}
+		if metadata.NextCursor != "" {
+			links = append(links, link("next", map[string]string{"cursor": metadata.NextCursor}))
+		}
+		return strings.Join(links, ", ")
+	}
+	links = append(links, link("first", map[string]string{"page": "1"}))
+	if filters.Page > 1 {
+		links = append(links, link("prev", map[string]string{"page": strconv.Itoa(filters.Page - 1)}))
+	}
+	if metadata.HasNextPage {
+		links = append(links, link("next", map[string]string{"page": strconv.Itoa(filters.Page + 1)}))
+	}
+	if metadata.LastPage > 0 {
+		links = append(links, link("last", map[string]string{"page": strconv.Itoa(metadata.LastPage)}))
+	}
+	return strings.Join(links, ", ")
+}
+
+func (app *application) readLocales(r *http.Request) []string {
+	type preference struct {
+		locale string
+		q      float64
+	}
+	var preferences []preference
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+		language, region, hasRegion := strings.Cut(tag, "-")
+		locale := strings.ToLower(language)
+		if hasRegion {
+			locale += "-" + strings.ToUpper(region)
+		}
+		preferences = append(preferences, preference{locale: locale, q: q})
+	}
+	sort.SliceStable(preferences, func(i, j int) bool {
+		return preferences[i].q > preferences[j].q
+	})
+	var locales []string
+	for _, p := range preferences {
+		locales = append(locales, p.locale)
+		if language, _, hasRegion := strings.Cut(p.locale, "-"); hasRegion {
+			locales = append(locales, language)
+		}
+	}
+	unique := locales[:0]
+	seen := make(map[string]bool)
+	for _, locale := range locales {
+		if !seen[locale] {
+			seen[locale] = true
+			unique = append(unique, locale)
+		}
+	}
+	return unique
+}
+
+func (app *application) every(ctx context.Context, interval time.Duration, name string, fn func(ctx context.Context) error) {
 	app.wg.Add(1)
 	go func() {
 		defer app.wg.Done()
-		defer func() {
-			if err := recover(); err != nil {
-				app.logger.Error(fmt.Sprintf("%v", err))
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
 			}
-		}()
-		fn()
+			func() {
+				defer func() {
+					if err := recover(); err != nil {
+						app.logger.Error(fmt.Sprintf("%v", err), "job", name)
+					}
+				}()
+				err := fn(ctx)
+				if err != nil && !errors.Is(err, context.Canceled) {
+					app.logger.Error(err.Error(), "job", name)
+				}
+			}()
+		}
 	}()
 }
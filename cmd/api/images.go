@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"greenlight.gustavosantos.net/internal/data"
+	"greenlight.gustavosantos.net/internal/imaging"
+	"greenlight.gustavosantos.net/internal/validator"
+)
+
+const (
+	imageMaxBytes  = 10 << 20
+	imageMinWidth  = 185
+	imageMinHeight = 185
+	imageMaxPixels = 40_000_000
+)
+
+var imageExtensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+func (app *application) attachImages(movies ...*data.Movie) error {
+	if len(movies) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(movies))
+	byID := make(map[int64]*data.Movie, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.ID
+		byID[movie.ID] = movie
+	}
+	images, err := app.models.MovieImages.GetAllForMovies(ids)
+	if err != nil {
+		return err
+	}
+	for _, img := range images {
+		movie := byID[img.MovieID]
+		if movie.Images == nil {
+			movie.Images = make(map[string]map[string]string)
+		}
+		urls := make(map[string]string, len(img.Variants))
+		for size, key := range img.Variants {
+			urls[size] = app.storage.URL(key)
+		}
+		movie.Images[img.Kind] = urls
+	}
+	return nil
+}
+
+func (app *application) deleteStoredImages(images ...*data.MovieImage) {
+	var keys []string
+	for _, img := range images {
+		for _, key := range img.Variants {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := app.jobs.Enqueue(ctx, jobDeleteStoredObjects, keys)
+	if err != nil {
+		app.logger.Error(err.Error(), "keys", strings.Join(keys, ","))
+	}
+}
+
+func (app *application) uploadMovieImageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Now().Add(time.Minute))
+	rc.SetWriteDeadline(time.Now().Add(time.Minute))
+	r.Body = http.MaxBytesReader(w, r.Body, imageMaxBytes+1_048_576)
+	err = r.ParseMultipartForm(imageMaxBytes)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.badRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", imageMaxBytes))
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	v := validator.New()
+	kind := r.FormValue("kind")
+	_, knownKind := data.ImageSizes[kind]
+	v.Check(knownKind, "kind", "must be poster or backdrop")
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		v.AddError("image", "must be provided")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	defer file.Close()
+	body, err := io.ReadAll(io.LimitReader(file, imageMaxBytes+1))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	v.Check(len(body) <= imageMaxBytes, "image", fmt.Sprintf("must not be larger than %d bytes", imageMaxBytes))
+	contentType := http.DetectContentType(body)
+	extension, supported := imageExtensions[contentType]
+	v.Check(supported, "image", "must be a JPEG, PNG or GIF image")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(body))
+	if err != nil {
+		v.AddError("image", "could not be decoded")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	v.Check(config.Width >= imageMinWidth, "image", fmt.Sprintf("must be at least %d pixels wide", imageMinWidth))
+	v.Check(config.Height >= imageMinHeight, "image", fmt.Sprintf("must be at least %d pixels high", imageMinHeight))
+	v.Check(config.Width*config.Height <= imageMaxPixels, "image", fmt.Sprintf("must not have more than %d pixels", imageMaxPixels))
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		v.AddError("image", "could not be decoded")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	uploaded := &data.MovieImage{
+		MovieID:     movie.ID,
+		Kind:        kind,
+		ContentType: contentType,
+		Width:       config.Width,
+		Height:      config.Height,
+		Variants:    make(map[string]string),
+	}
+	prefix := fmt.Sprintf("movies/%d/%s/%d", movie.ID, kind, time.Now().UnixNano())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	originalKey := fmt.Sprintf("%s/original.%s", prefix, extension)
+	err = app.storage.Put(ctx, originalKey, body, contentType)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	uploaded.Variants["original"] = originalKey
+	source := imaging.RGBA(decoded)
+	for _, width := range data.ImageSizes[kind] {
+		if width >= config.Width {
+			continue
+		}
+		thumbnail, encodeErr := imaging.EncodeJPEG(imaging.Resize(source, width), 85)
+		if encodeErr != nil {
+			app.deleteStoredImages(uploaded)
+			app.serverErrorResponse(w, r, encodeErr)
+			return
+		}
+		key := fmt.Sprintf("%s/w%d.jpg", prefix, width)
+		putErr := app.storage.Put(ctx, key, thumbnail, "image/jpeg")
+		if putErr != nil {
+			app.deleteStoredImages(uploaded)
+			app.serverErrorResponse(w, r, putErr)
+			return
+		}
+		uploaded.Variants[fmt.Sprintf("w%d", width)] = key
+	}
+	previous, err := app.models.MovieImages.Get(movie.ID, kind)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.deleteStoredImages(uploaded)
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.models.MovieImages.Upsert(uploaded)
+	if err != nil {
+		app.deleteStoredImages(uploaded)
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if previous != nil {
+		app.deleteStoredImages(previous)
+	}
+	err = app.attachImages(movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": movie}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"greenlight.gustavosantos.net/internal/data"
+	"greenlight.gustavosantos.net/internal/validator"
+)
+
+const (
+	importModeAllOrNothing = "all_or_nothing"
+	importModeBestEffort   = "best_effort"
+	importBatchSize        = 500
+	importMaxBytes         = 50 << 20
+)
+
+type importRow struct {
+	line  int
+	movie *data.Movie
+	err   error
+}
+
+type movieRowReader interface {
+	next() (importRow, error)
+}
+
+type csvMovieReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+func newCSVMovieReader(r io.Reader) (*csvMovieReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("body must not be empty")
+		}
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"title", "year", "runtime", "genres"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("csv header must contain a %q column", required)
+		}
+	}
+	return &csvMovieReader{reader: reader, columns: columns}, nil
+}
+
+func (cr *csvMovieReader) next() (importRow, error) {
+	record, err := cr.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return importRow{line: parseErr.StartLine, err: parseErr.Err}, nil
+		}
+		return importRow{}, err
+	}
+	line, _ := cr.reader.FieldPos(0)
+	field := func(name string) string {
+		i := cr.columns[name]
+		if i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	movie := &data.Movie{Title: field("title")}
+	if s := field("year"); s != "" {
+		year, parseErr := strconv.ParseInt(s, 10, 32)
+		if parseErr != nil {
+			return importRow{line: line, err: errors.New("year must be an integer value")}, nil
+		}
+		movie.Year = int32(year)
+	}
+	if s := field("runtime"); s != "" {
+		runtime, parseErr := strconv.ParseInt(strings.TrimSuffix(s, " mins"), 10, 32)
+		if parseErr != nil {
+			return importRow{line: line, err: data.ErrInvalidRuntimeFormat}, nil
+		}
+		movie.Runtime = data.Runtime(runtime)
+	}
+	if s := field("genres"); s != "" {
+		movie.Genres = []string{}
+		for _, genre := range strings.Split(s, ",") {
+			movie.Genres = append(movie.Genres, strings.TrimSpace(genre))
+		}
+	}
+	return importRow{line: line, movie: movie}, nil
+}
+
+type ndjsonMovieReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newNDJSONMovieReader(r io.Reader) *ndjsonMovieReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1_048_576)
+	return &ndjsonMovieReader{scanner: scanner}
+}
+
+func (nr *ndjsonMovieReader) next() (importRow, error) {
+	for nr.scanner.Scan() {
+		nr.line++
+		raw := bytes.TrimSpace(nr.scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		var input struct {
+			Title   string       `json:"title"`
+			Year    int32        `json:"year"`
+			Runtime data.Runtime `json:"runtime"`
+			Genres  []string     `json:"genres"`
+		}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&input)
+		if err != nil {
+			return importRow{line: nr.line, err: err}, nil
+		}
+		movie := &data.Movie{
+			Title:   input.Title,
+			Year:    input.Year,
+			Runtime: input.Runtime,
+			Genres:  input.Genres,
+		}
+		return importRow{line: nr.line, movie: movie}, nil
+	}
+	if err := nr.scanner.Err(); err != nil {
+		return importRow{}, err
+	}
+	return importRow{}, io.EOF
+}
+
+type importReport struct {
+	DryRun    bool                      `json:"dry_run"`
+	Mode      string                    `json:"mode"`
+	TotalRows int                       `json:"total_rows"`
+	ValidRows int                       `json:"valid_rows"`
+	Committed bool                      `json:"committed"`
+	Created   map[int]int64             `json:"created"`
+	Errors    map[int]map[string]string `json:"errors"`
+}
+
+func (app *application) importMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+	dryRun := app.readBool(qs, "dry_run", false, v)
+	mode := app.readString(qs, "mode", importModeAllOrNothing)
+	v.Check(validator.PermittedValue(mode, importModeAllOrNothing, importModeBestEffort), "mode", "invalid mode value")
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	v.Check(validator.PermittedValue(mediaType, "text/csv", "application/x-ndjson"), "content_type", "must be text/csv or application/x-ndjson")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Now().Add(5 * time.Minute))
+	rc.SetWriteDeadline(time.Now().Add(5 * time.Minute))
+	r.Body = http.MaxBytesReader(w, r.Body, importMaxBytes)
+	var rows movieRowReader
+	switch mediaType {
+	case "text/csv":
+		csvReader, err := newCSVMovieReader(r.Body)
+		if err != nil {
+			app.badRequestResponse(w, r, importStreamError(err))
+			return
+		}
+		rows = csvReader
+	default:
+		rows = newNDJSONMovieReader(r.Body)
+	}
+	genres, lookupErr := app.models.Genres.Lookup()
+	if lookupErr != nil {
+		app.serverErrorResponse(w, r, lookupErr)
+		return
+	}
+	report := importReport{
+		DryRun:  dryRun,
+		Mode:    mode,
+		Created: make(map[int]int64),
+		Errors:  make(map[int]map[string]string),
+	}
+	var (
+		batch        *data.MovieBatch
+		pending      []*data.Movie
+		pendingLines []int
+	)
+	defer func() {
+		if batch != nil {
+			batch.Rollback()
+		}
+	}()
+	flush := func() error {
+		defer func() {
+			pending = pending[:0]
+			pendingLines = pendingLines[:0]
+		}()
+		if len(pending) == 0 || (mode == importModeAllOrNothing && len(report.Errors) > 0) {
+			return nil
+		}
+		if batch == nil {
+			var err error
+			batch, err = app.models.Movies.BeginBatch()
+			if err != nil {
+				return err
+			}
+		}
+		err := batch.Insert(pending)
+		if err != nil {
+			return err
+		}
+		if mode == importModeBestEffort {
+			err = batch.Commit()
+			batch = nil
+			if err != nil {
+				return err
+			}
+		}
+		for i, movie := range pending {
+			report.Created[pendingLines[i]] = movie.ID
+		}
+		return nil
+	}
+	for {
+		row, err := rows.next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			app.badRequestResponse(w, r, importStreamError(err))
+			return
+		}
+		report.TotalRows++
+		if row.err != nil {
+			report.Errors[row.line] = map[string]string{"row": row.err.Error()}
+			continue
+		}
+		rowValidator := validator.New()
+		if data.ValidateMovie(rowValidator, row.movie, genres); !rowValidator.Valid() {
+			report.Errors[row.line] = rowValidator.Errors
+			continue
+		}
+		report.ValidRows++
+		if dryRun {
+			continue
+		}
+		pending = append(pending, row.movie)
+		pendingLines = append(pendingLines, row.line)
+		if len(pending) >= importBatchSize {
+			if flushErr := flush(); flushErr != nil {
+				app.serverErrorResponse(w, r, flushErr)
+				return
+			}
+		}
+	}
+	status := http.StatusOK
+	if !dryRun {
+		if flushErr := flush(); flushErr != nil {
+			app.serverErrorResponse(w, r, flushErr)
+			return
+		}
+		if mode == importModeAllOrNothing && len(report.Errors) > 0 {
+			report.Created = make(map[int]int64)
+			status = http.StatusUnprocessableEntity
+		} else {
+			if batch != nil {
+				commitErr := batch.Commit()
+				batch = nil
+				if commitErr != nil {
+					app.serverErrorResponse(w, r, commitErr)
+					return
+				}
+			}
+			report.Committed = true
+			status = http.StatusCreated
+		}
+		if len(report.Created) > 0 {
+			app.similar.clear()
+		}
+	}
+	writeJsonErr := app.writeJSON(w, status, envelope{"import": report}, nil)
+	if writeJsonErr != nil {
+		app.serverErrorResponse(w, r, writeJsonErr)
+	}
+}
+
+func importStreamError(err error) error {
+	var maxBytesError *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesError):
+		return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+	case errors.Is(err, bufio.ErrTooLong):
+		return errors.New("body contains a line longer than 1048576 bytes")
+	default:
+		return err
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"greenlight.gustavosantos.net/internal/data"
+	"greenlight.gustavosantos.net/internal/jobs"
+	"greenlight.gustavosantos.net/internal/storage"
+)
+
+const (
+	jobSendUserEmail       = "mail.user"
+	jobDeleteStoredObjects = "storage.delete"
+)
+
+type userEmailJob struct {
+	Topic  string `json:"topic"`
+	UserID int64  `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+func (app *application) registerJobHandlers() {
+	jobs.Handle(app.jobs, jobSendUserEmail, app.sendUserEmail)
+	jobs.Handle(app.jobs, jobDeleteStoredObjects, app.deleteStoredObjects)
+}
+
+func (app *application) sendUserEmail(ctx context.Context, job userEmailJob) error {
+	switch job.Topic {
+	case data.TopicUserRegistered:
+		token, err := app.models.Tokens.Replace(ctx, job.UserID, 3*24*time.Hour, data.ScopeActivation)
+		if err != nil {
+			return err
+		}
+		return app.mailer.Send(job.Email, "user_welcome.tmpl", map[string]any{
+			"activationToken": token.Plaintext,
+			"userID":          job.UserID,
+		})
+	case data.TopicActivationRequested:
+		token, err := app.models.Tokens.Replace(ctx, job.UserID, 3*24*time.Hour, data.ScopeActivation)
+		if err != nil {
+			return err
+		}
+		return app.mailer.Send(job.Email, "token_activation.tmpl", map[string]any{
+			"activationToken": token.Plaintext,
+		})
+	case data.TopicPasswordResetRequested:
+		token, err := app.models.Tokens.Replace(ctx, job.UserID, 45*time.Minute, data.ScopePasswordReset)
+		if err != nil {
+			return err
+		}
+		return app.mailer.Send(job.Email, "token_password_reset.tmpl", map[string]any{
+			"passwordResetToken": token.Plaintext,
+		})
+	default:
+		return jobs.Permanent(fmt.Errorf("no email for topic %q", job.Topic))
+	}
+}
+
+func (app *application) deleteStoredObjects(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		err := app.storage.Delete(ctx, key)
+		if err != nil && !errors.Is(err, storage.ErrObjectNotFound) {
+			return err
+		}
+	}
+	return nil
+}
@@ -17,7 +17,10 @@ import (
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 	"greenlight.gustavosantos.net/internal/data"
+	"greenlight.gustavosantos.net/internal/jobs"
 	"greenlight.gustavosantos.net/internal/mailer"
+	"greenlight.gustavosantos.net/internal/scheduler"
+	"greenlight.gustavosantos.net/internal/storage"
 	"greenlight.gustavosantos.net/internal/vcs"
 )
 
@@ -52,14 +55,67 @@ type config struct {
 	jwt struct {
 		secret string
 	}
+	search struct {
+		fuzzyThreshold     float64
+		duplicateThreshold float64
+	}
+	baseURL string
+	digest  struct {
+		period   time.Duration
+		schedule string
+	}
+	trending struct {
+		flushInterval   time.Duration
+		refreshSchedule string
+	}
+	scheduler struct {
+		enabled bool
+	}
+	jobs struct {
+		concurrency  int
+		pollInterval time.Duration
+		lease        time.Duration
+	}
+	outbox struct {
+		interval time.Duration
+	}
+	webhooks struct {
+		interval time.Duration
+	}
+	recommendations struct {
+		schedule string
+		perUser  int
+	}
+	storage struct {
+		backend  string
+		localDir string
+		localURL string
+		s3       struct {
+			endpoint  string
+			region    string
+			bucket    string
+			accessKey string
+			secretKey string
+			publicURL string
+			pathStyle bool
+		}
+	}
 }
 
 type application struct {
-	config config
-	logger *slog.Logger
-	models data.Models
-	mailer mailer.Mailer
-	wg     sync.WaitGroup
+	config    config
+	logger    *slog.Logger
+	models    data.Models
+	mailer    mailer.Mailer
+	storage   storage.Storage
+	scorer    data.SimilarityScorer
+	similar   *similarCache
+	views     *viewCounter
+	events    *movieEventHub
+	presence  *presenceHub
+	jobs      *jobs.Queue
+	scheduler *scheduler.Scheduler
+	wg        sync.WaitGroup
 }
 
 func main() {
@@ -154,6 +210,31 @@ func main() {
 		return nil
 	})
 	flag.StringVar(&cfg.jwt.secret, "jwt-secret", "", "JWT secret")
+	flag.Float64Var(&cfg.search.fuzzyThreshold, "search-fuzzy-threshold", 0.3, "Minimum trigram word similarity for fuzzy title matches")
+	flag.Float64Var(&cfg.search.duplicateThreshold, "search-duplicate-threshold", 0.6, "Minimum normalized title similarity for possible duplicate movies")
+	flag.StringVar(&cfg.baseURL, "base-url", "http://localhost:4000", "Public base URL of the API, used in email links")
+	flag.DurationVar(&cfg.digest.period, "digest-period", 7*24*time.Hour, "Minimum time between notification digests for a user")
+	flag.StringVar(&cfg.digest.schedule, "digest-schedule", "@hourly", "Cron schedule for pending notification digest checks (empty disables digests)")
+	flag.DurationVar(&cfg.trending.flushInterval, "trending-flush-interval", 10*time.Second, "Interval between movie view count flushes")
+	flag.StringVar(&cfg.trending.refreshSchedule, "trending-refresh-schedule", "*/5 * * * *", "Cron schedule for trending score refreshes")
+	flag.BoolVar(&cfg.scheduler.enabled, "scheduler-enabled", true, "Run periodic maintenance tasks in this instance")
+	flag.IntVar(&cfg.jobs.concurrency, "jobs-concurrency", 4, "Number of background job workers")
+	flag.DurationVar(&cfg.jobs.pollInterval, "jobs-poll-interval", time.Second, "Interval between polls for queued background jobs")
+	flag.DurationVar(&cfg.jobs.lease, "jobs-lease", 5*time.Minute, "Maximum run time of a background job before another worker may retry it")
+	flag.DurationVar(&cfg.outbox.interval, "outbox-interval", time.Second, "Interval between polls for unpublished outbox entries")
+	flag.DurationVar(&cfg.webhooks.interval, "webhooks-interval", 5*time.Second, "Interval between polls for pending webhook deliveries (0 disables delivery)")
+	flag.StringVar(&cfg.recommendations.schedule, "recommendations-schedule", "@hourly", "Cron schedule for recommendation refreshes (empty disables the refresh job)")
+	flag.IntVar(&cfg.recommendations.perUser, "recommendations-per-user", 50, "Number of precomputed recommendations stored per user")
+	flag.StringVar(&cfg.storage.backend, "storage-backend", "local", "Image storage backend (local|s3)")
+	flag.StringVar(&cfg.storage.localDir, "storage-local-dir", "./uploads", "Directory for the local image storage backend")
+	flag.StringVar(&cfg.storage.localURL, "storage-local-url", "/images", "Base URL for images served from the local storage backend")
+	flag.StringVar(&cfg.storage.s3.endpoint, "s3-endpoint", os.Getenv("S3_ENDPOINT"), "S3-compatible endpoint URL")
+	flag.StringVar(&cfg.storage.s3.region, "s3-region", "us-east-1", "S3 region")
+	flag.StringVar(&cfg.storage.s3.bucket, "s3-bucket", os.Getenv("S3_BUCKET"), "S3 bucket")
+	flag.StringVar(&cfg.storage.s3.accessKey, "s3-access-key", os.Getenv("S3_ACCESS_KEY"), "S3 access key")
+	flag.StringVar(&cfg.storage.s3.secretKey, "s3-secret-key", os.Getenv("S3_SECRET_KEY"), "S3 secret key")
+	flag.StringVar(&cfg.storage.s3.publicURL, "s3-public-url", "", "Public base URL for stored images (defaults to the bucket URL)")
+	flag.BoolVar(&cfg.storage.s3.pathStyle, "s3-path-style", true, "Use path-style S3 requests")
 	displayVersion := flag.Bool("version", false, "Display version and exit")
 	flag.Parse()
 	if *displayVersion {
@@ -177,11 +258,30 @@ func main() {
 	expvar.Publish("timestamp", expvar.Func(func() any {
 		return time.Now().Unix()
 	}))
+	store, storageErr := openStorage(cfg)
+	if storageErr != nil {
+		logger.Error(storageErr.Error())
+		os.Exit(1)
+	}
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
-		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		config:    cfg,
+		logger:    logger,
+		models:    data.NewModels(db),
+		mailer:    mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		storage:   store,
+		scorer:    data.DefaultSimilarityScorer(),
+		similar:   newSimilarCache(),
+		views:     newViewCounter(),
+		events:    newMovieEventHub(),
+		presence:  newPresenceHub(data.MovieEventModel{DB: db}, logger),
+		jobs:      jobs.New(db, logger, cfg.jobs.concurrency, cfg.jobs.pollInterval, cfg.jobs.lease),
+		scheduler: scheduler.New(db, logger),
+	}
+	app.registerJobHandlers()
+	scheduleErr := app.scheduleTasks()
+	if scheduleErr != nil {
+		logger.Error(scheduleErr.Error())
+		os.Exit(1)
 	}
 	err := app.serve()
 	if err != nil {
@@ -190,6 +290,18 @@ func main() {
 	}
 }
 
+func openStorage(cfg config) (storage.Storage, error) {
+	switch cfg.storage.backend {
+	case "local":
+		return storage.NewLocal(cfg.storage.localDir, cfg.storage.localURL)
+	case "s3":
+		s3 := cfg.storage.s3
+		return storage.NewS3(s3.endpoint, s3.region, s3.bucket, s3.accessKey, s3.secretKey, s3.publicURL, s3.pathStyle)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.storage.backend)
+	}
+}
+
 func openDB(cfg config) (*sql.DB, error) {
 	db, err := sql.Open("postgres", cfg.db.dsn)
 	if err != nil {
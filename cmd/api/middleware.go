@@ -16,6 +16,7 @@ import (
 
 	"greenlight.gustavosantos.net/internal/data"
 	"greenlight.gustavosantos.net/internal/validator"
+	"greenlight.gustavosantos.net/internal/websocket"
 )
 
 type metricsResponseWriter struct {
@@ -155,41 +156,76 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			app.invalidAuthenticationTokenResponse(w, r)
 			return
 		}
-		token := headerParts[1]
-        claims, err := jwt.HMACCheck([]byte(token), []byte(app.config.jwt.secret))
-        if err != nil {
-            app.invalidAuthenticationTokenResponse(w, r)
-            return
-        }
-        if !claims.Valid(time.Now()) {
-            app.invalidAuthenticationTokenResponse(w, r)
-            return
-        }
-        if claims.Issuer != "greenlight.teste.net" {
-            app.invalidAuthenticationTokenResponse(w, r)
-            return
-        }
-        if !claims.AcceptAudience("greenlight.teste.net") {
-            app.invalidAuthenticationTokenResponse(w, r)
-            return
-        }
-        userID, err := strconv.ParseInt(claims.Subject, 10, 64)
-        if err != nil {
-            app.serverErrorResponse(w, r, err)
-            return
-        }
-        user, err := app.models.Users.Get(userID)
-        if err != nil {
-            switch {
-            case errors.Is(err, data.ErrRecordNotFound):
-                app.invalidAuthenticationTokenResponse(w, r)
-            default:
-                app.serverErrorResponse(w, r, err)
-            }
-            return
-        }
-        r = app.contextSetUser(r, user)
-        next.ServeHTTP(w, r)
+		user, err := app.userForToken(headerParts[1])
+		if err != nil {
+			switch {
+			case errors.Is(err, errInvalidToken):
+				app.invalidAuthenticationTokenResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+		r = app.contextSetUser(r, user)
+		next.ServeHTTP(w, r)
+	})
+}
+
+var errInvalidToken = errors.New("invalid authentication token")
+
+func (app *application) userForToken(token string) (*data.User, error) {
+	claims, err := jwt.HMACCheck([]byte(token), []byte(app.config.jwt.secret))
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	if !claims.Valid(time.Now()) {
+		return nil, errInvalidToken
+	}
+	if claims.Issuer != "greenlight.teste.net" {
+		return nil, errInvalidToken
+	}
+	if !claims.AcceptAudience("greenlight.teste.net") {
+		return nil, errInvalidToken
+	}
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	user, err := app.models.Users.Get(userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil, errInvalidToken
+		default:
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+func (app *application) authenticateWebSocket(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var token string
+		for _, protocol := range websocket.Subprotocols(r) {
+			if t, ok := strings.CutPrefix(protocol, websocketBearerPrefix); ok {
+				token = t
+			}
+		}
+		if token == "" || !app.contextGetUser(r).IsAnnonymous() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		user, err := app.userForToken(token)
+		if err != nil {
+			switch {
+			case errors.Is(err, errInvalidToken):
+				app.invalidAuthenticationTokenResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+		next.ServeHTTP(w, app.contextSetUser(r, user))
 	})
 }
 
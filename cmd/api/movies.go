@@ -4,25 +4,52 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
+	"github.com/julienschmidt/httprouter"
 	"greenlight.gustavosantos.net/internal/data"
 	"greenlight.gustavosantos.net/internal/validator"
 )
 
+func (app *application) readMovieCriteria(qs url.Values, v *validator.Validator) data.MovieCriteria {
+	return data.MovieCriteria{
+		Title:          app.readString(qs, "title", ""),
+		Fuzzy:          app.readBool(qs, "fuzzy", false, v),
+		FuzzyThreshold: app.config.search.fuzzyThreshold,
+		Genres:         app.readCSV(qs, "genres", []string{}),
+		GenresAny:      app.readCSV(qs, "genres_any", []string{}),
+		GenresExclude:  app.readCSV(qs, "genres_exclude", []string{}),
+		YearMin:        app.readInt(qs, "year_min", 0, v),
+		YearMax:        app.readInt(qs, "year_max", 0, v),
+		RuntimeMin:     app.readInt(qs, "runtime_min", 0, v),
+		RuntimeMax:     app.readInt(qs, "runtime_max", 0, v),
+		CreatedAfter:   app.readTime(qs, "created_after", v),
+		CreatedBefore:  app.readTime(qs, "created_before", v),
+		IDs:            app.readInt64CSV(qs, "ids", v),
+		CollectionID:   int64(app.readInt(qs, "collection_id", 0, v)),
+	}
+}
+
 func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title  string
-		Genres []string
+		data.MovieCriteria
 		data.Filters
 	}
 	v := validator.New()
 	qs := r.URL.Query()
-	input.Title = app.readString(qs, "title", "")
-	input.Genres = app.readCSV(qs, "genres", []string{})
+	input.MovieCriteria = app.readMovieCriteria(qs, v)
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
 	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.Cursor = app.readString(qs, "cursor", "")
+	pagination := app.readString(qs, "pagination", "offset")
+	v.Check(validator.PermittedValue(pagination, "offset", "cursor"), "pagination", "invalid pagination value")
+	input.Filters.CursorMode = pagination == "cursor" || input.Filters.Cursor != ""
+	input.Filters.CursorSecret = []byte(app.config.jwt.secret)
+	input.Filters.SkipCount = !app.readBool(qs, "include_total", true, v)
+	includeFacets := app.readBool(qs, "facets", false, v)
 	input.Filters.SortSafelist = []string{
 		"id",
 		"-id",
@@ -32,17 +59,48 @@ func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request
 		"-year",
 		"runtime",
 		"-runtime",
+		"collection",
+		"-collection",
 	}
+	if strings.Contains(input.Filters.Sort, "collection") {
+		v.Check(input.MovieCriteria.CollectionID > 0, "sort", "collection order requires collection_id")
+		v.Check(!input.Filters.CursorMode, "sort", "collection order is not supported with cursor pagination")
+	}
+	data.ValidateMovieCriteria(v, input.MovieCriteria)
 	if data.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	movies, metadata, getAllErr := app.models.Movies.GetAll(input.Title, input.Genres, input.Filters)
+	movies, metadata, getAllErr := app.models.Movies.GetAll(input.MovieCriteria, input.Filters)
 	if getAllErr != nil {
 		app.serverErrorResponse(w, r, getAllErr)
 		return
 	}
-	writeJsonErr := app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
+	attachErr := app.attachImages(movies...)
+	if attachErr != nil {
+		app.serverErrorResponse(w, r, attachErr)
+		return
+	}
+	localizeErr := app.models.MovieTranslations.Localize(app.readLocales(r), movies...)
+	if localizeErr != nil {
+		app.serverErrorResponse(w, r, localizeErr)
+		return
+	}
+	env := envelope{"movies": movies, "metadata": metadata}
+	if includeFacets {
+		facets, facetsErr := app.models.Movies.Facets(input.MovieCriteria)
+		if facetsErr != nil {
+			app.serverErrorResponse(w, r, facetsErr)
+			return
+		}
+		env["facets"] = facets
+	}
+	headers := make(http.Header)
+	headers.Set("Vary", "Accept-Language")
+	if links := app.paginationLinks(r, input.Filters, metadata); links != "" {
+		headers.Set("Link", links)
+	}
+	writeJsonErr := app.writeJSON(w, http.StatusOK, env, headers)
 	if writeJsonErr != nil {
 		app.serverErrorResponse(w, r, writeJsonErr)
 	}
@@ -50,10 +108,14 @@ func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request
 
 func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title   string       `json:"title"`
-		Year    int32        `json:"year"`
-		Runtime data.Runtime `json:"runtime"`
-		Genres  []string     `json:"genres"`
+		Title            string                       `json:"title"`
+		Year             int32                        `json:"year"`
+		Runtime          data.Runtime                 `json:"runtime"`
+		Genres           []string                     `json:"genres"`
+		Overview         string                       `json:"overview"`
+		OriginalLanguage string                       `json:"original_language"`
+		Releases         map[string]data.MovieRelease `json:"releases"`
+		ExternalIDs      *data.ExternalIDs            `json:"external_ids"`
 	}
 	err := app.readJSON(w, r, &input)
 	if err != nil {
@@ -61,23 +123,61 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	movie := &data.Movie{
-		Title:   input.Title,
-		Year:    input.Year,
-		Runtime: input.Runtime,
-		Genres:  input.Genres,
+		Title:            input.Title,
+		Year:             input.Year,
+		Runtime:          input.Runtime,
+		Genres:           input.Genres,
+		Overview:         input.Overview,
+		OriginalLanguage: input.OriginalLanguage,
+		Releases:         input.Releases,
+		ExternalIDs:      input.ExternalIDs,
+	}
+	genres, lookupErr := app.models.Genres.Lookup()
+	if lookupErr != nil {
+		app.serverErrorResponse(w, r, lookupErr)
+		return
 	}
 	v := validator.New()
-	if data.ValidateMovie(v, movie); !v.Valid() {
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	force := app.readBool(r.URL.Query(), "force", false, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	duplicates, duplicatesErr := app.models.Movies.PossibleDuplicates(movie, app.config.search.duplicateThreshold)
+	if duplicatesErr != nil {
+		app.serverErrorResponse(w, r, duplicatesErr)
+		return
+	}
+	if len(duplicates) > 0 && !force {
+		app.possibleDuplicatesResponse(w, r, duplicates)
+		return
 	}
 	insertErr := app.models.Movies.Insert(movie)
 	if insertErr != nil {
-		app.serverErrorResponse(w, r, insertErr)
+		switch {
+		case errors.Is(insertErr, data.ErrDuplicateIMDbID):
+			v.AddError("external_ids.imdb", "a movie with this IMDb ID already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(insertErr, data.ErrDuplicateTMDBID):
+			v.AddError("external_ids.tmdb", "a movie with this TMDB ID already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, insertErr)
+		}
 		return
 	}
+	app.similar.clear()
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
-	writeJsonErr := app.writeJSON(w, http.StatusCreated, envelope{"movie": movie}, headers)
+	env := envelope{"movie": movie}
+	if len(duplicates) > 0 {
+		env["possible_duplicates"] = duplicates
+	}
+	writeJsonErr := app.writeJSON(w, http.StatusCreated, env, headers)
 	if writeJsonErr != nil {
 		app.serverErrorResponse(w, r, writeJsonErr)
 	}
@@ -99,12 +199,76 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 		}
 		return
 	}
-	writeJsonErr := app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	app.views.record(movie.ID)
+	attachErr := app.attachImages(movie)
+	if attachErr != nil {
+		app.serverErrorResponse(w, r, attachErr)
+		return
+	}
+	collection, collectionErr := app.models.Collections.GetSummaryForMovie(movie.ID)
+	if collectionErr != nil && !errors.Is(collectionErr, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, collectionErr)
+		return
+	}
+	movie.Collection = collection
+	localizeErr := app.models.MovieTranslations.Localize(app.readLocales(r), movie)
+	if localizeErr != nil {
+		app.serverErrorResponse(w, r, localizeErr)
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Vary", "Accept-Language")
+	if movie.Locale != "" {
+		headers.Set("Content-Language", movie.Locale)
+	}
+	writeJsonErr := app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, headers)
 	if writeJsonErr != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
+func (app *application) showMovieByExternalIDHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	var (
+		movie  *data.Movie
+		getErr error
+	)
+	switch params.ByName("source") {
+	case data.ExternalSourceIMDb:
+		movie, getErr = app.models.Movies.GetByIMDbID(params.ByName("id"))
+	case data.ExternalSourceTMDB:
+		tmdbID, parseErr := strconv.ParseInt(params.ByName("id"), 10, 64)
+		if parseErr != nil {
+			app.notFoundResponse(w, r)
+			return
+		}
+		movie, getErr = app.models.Movies.GetByTMDBID(tmdbID)
+	default:
+		app.notFoundResponse(w, r)
+		return
+	}
+	if getErr != nil {
+		switch {
+		case errors.Is(getErr, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, getErr)
+		}
+		return
+	}
+	attachErr := app.attachImages(movie)
+	if attachErr != nil {
+		app.serverErrorResponse(w, r, attachErr)
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Content-Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
+	writeJsonErr := app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, headers)
+	if writeJsonErr != nil {
+		app.serverErrorResponse(w, r, writeJsonErr)
+	}
+}
+
 func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil {
@@ -128,10 +292,14 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		}
 	}
 	var input struct {
-		Title   *string       `json:"title"`
-		Year    *int32        `json:"year"`
-		Runtime *data.Runtime `json:"runtime"`
-		Genres  []string      `json:"genres"`
+		Title            *string                      `json:"title"`
+		Year             *int32                       `json:"year"`
+		Runtime          *data.Runtime                `json:"runtime"`
+		Genres           []string                     `json:"genres"`
+		Overview         *string                      `json:"overview"`
+		OriginalLanguage *string                      `json:"original_language"`
+		Releases         map[string]data.MovieRelease `json:"releases"`
+		ExternalIDs      *data.ExternalIDs            `json:"external_ids"`
 	}
 	readErr := app.readJSON(w, r, &input)
 	if readErr != nil {
@@ -150,8 +318,25 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	if input.Genres != nil {
 		movie.Genres = input.Genres
 	}
+	if input.Overview != nil {
+		movie.Overview = *input.Overview
+	}
+	if input.OriginalLanguage != nil {
+		movie.OriginalLanguage = *input.OriginalLanguage
+	}
+	if input.Releases != nil {
+		movie.Releases = input.Releases
+	}
+	if input.ExternalIDs != nil {
+		movie.ExternalIDs = input.ExternalIDs
+	}
+	genres, lookupErr := app.models.Genres.Lookup()
+	if lookupErr != nil {
+		app.serverErrorResponse(w, r, lookupErr)
+		return
+	}
 	v := validator.New()
-	if data.ValidateMovie(v, movie); !v.Valid() {
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
@@ -160,11 +345,23 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		switch {
 		case errors.Is(updateErr, data.ErrEditConflict):
 			app.editConflictResponse(w, r)
+		case errors.Is(updateErr, data.ErrDuplicateIMDbID):
+			v.AddError("external_ids.imdb", "a movie with this IMDb ID already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(updateErr, data.ErrDuplicateTMDBID):
+			v.AddError("external_ids.tmdb", "a movie with this TMDB ID already exists")
+			app.failedValidationResponse(w, r, v.Errors)
 		default:
 			app.serverErrorResponse(w, r, updateErr)
 		}
 		return
 	}
+	app.similar.clear()
+	attachErr := app.attachImages(movie)
+	if attachErr != nil {
+		app.serverErrorResponse(w, r, attachErr)
+		return
+	}
 	writeJsonErr := app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
 	if writeJsonErr != nil {
 		app.serverErrorResponse(w, r, err)
@@ -177,6 +374,11 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		app.notFoundResponse(w, r)
 		return
 	}
+	images, imagesErr := app.models.MovieImages.GetAllForMovies([]int64{id})
+	if imagesErr != nil {
+		app.serverErrorResponse(w, r, imagesErr)
+		return
+	}
 	deleteErr := app.models.Movies.Delete(id)
 	if deleteErr != nil {
 		switch {
@@ -185,7 +387,10 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		default:
 			app.serverErrorResponse(w, r, deleteErr)
 		}
+		return
 	}
+	app.deleteStoredImages(images...)
+	app.similar.clear()
 	writeJsonErr := app.writeJSON(w, http.StatusOK, envelope{"message": "movie successfully deleted"}, nil)
 	if writeJsonErr != nil {
 		app.serverErrorResponse(w, r, err)
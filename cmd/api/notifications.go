@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pascaldekloe/jwt"
+	"greenlight.gustavosantos.net/internal/data"
+	"greenlight.gustavosantos.net/internal/validator"
+)
+
+const unsubscribeAudience = "greenlight.teste.net/unsubscribe"
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!doctype html>
+<html>
+
+<head>
+    <meta name="viewport" content="width=device-width" />
+    <meta http-equiv="Content-Type" content="text/html; charset=UTF-8" />
+    <title>Unsubscribe from the weekly digest</title>
+</head>
+
+<body>
+    <p>Do you want to stop receiving the Greenlight weekly digest?</p>
+    <form method="post" action="/v1/notifications/unsubscribe?token={{.}}">
+        <button type="submit">Unsubscribe</button>
+    </form>
+</body>
+
+</html>
+`))
+
+func (app *application) unsubscribeToken(userID int64) (string, error) {
+	var claims jwt.Claims
+	claims.Subject = strconv.FormatInt(userID, 10)
+	claims.Issued = jwt.NewNumericTime(time.Now())
+	claims.Expires = jwt.NewNumericTime(time.Now().Add(365 * 24 * time.Hour))
+	claims.Issuer = "greenlight.teste.net"
+	claims.Audiences = []string{unsubscribeAudience}
+	token, err := claims.HMACSign(jwt.HS256, []byte(app.config.jwt.secret))
+	if err != nil {
+		return "", err
+	}
+	return string(token), nil
+}
+
+func (app *application) unsubscribeUserID(token string) (int64, bool) {
+	claims, err := jwt.HMACCheck([]byte(token), []byte(app.config.jwt.secret))
+	if err != nil || !claims.Valid(time.Now()) || !claims.AcceptAudience(unsubscribeAudience) {
+		return 0, false
+	}
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return userID, true
+}
+
+func (app *application) sendDigests(ctx context.Context) error {
+	userIDs, err := app.models.Notifications.DigestRecipients(ctx, app.config.digest.period, 100)
+	if err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		user, err := app.models.Users.Get(userID)
+		if err != nil {
+			app.logger.Error(err.Error(), "job", "digest", "user_id", userID)
+			continue
+		}
+		token, err := app.unsubscribeToken(user.ID)
+		if err != nil {
+			return err
+		}
+		claim, err := app.models.Notifications.ClaimDigest(ctx, user.ID, app.config.digest.period)
+		if err != nil {
+			app.logger.Error(err.Error(), "job", "digest", "user_id", user.ID)
+			continue
+		}
+		if claim == nil || len(claim.Items) == 0 {
+			continue
+		}
+		unsubscribeURL := app.config.baseURL + "/v1/notifications/unsubscribe?token=" + url.QueryEscape(token)
+		err = app.mailer.SendWithHeaders(user.Email, "user_digest.tmpl", map[string]any{
+			"name":           user.Name,
+			"movies":         claim.Items,
+			"unsubscribeURL": unsubscribeURL,
+		}, map[string]string{
+			"List-Unsubscribe":      "<" + unsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		})
+		if err != nil {
+			app.logger.Error(err.Error(), "job", "digest", "user_id", user.ID)
+			releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 3*time.Second)
+			err = app.models.Notifications.ReleaseDigest(releaseCtx, claim)
+			cancel()
+			if err != nil {
+				app.logger.Error(err.Error(), "job", "digest", "user_id", user.ID)
+			}
+		}
+	}
+	return nil
+}
+
+func (app *application) showNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	preferences, err := app.models.Notifications.GetPreferences(app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"notifications": preferences}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	preferences, err := app.models.Notifications.GetPreferences(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	var input struct {
+		DigestEnabled *bool               `json:"digest_enabled"`
+		Subscriptions []data.Subscription `json:"subscriptions"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if input.DigestEnabled != nil {
+		preferences.DigestEnabled = *input.DigestEnabled
+	}
+	if input.Subscriptions != nil {
+		preferences.Subscriptions = input.Subscriptions
+	}
+	genres, err := app.models.Genres.Lookup()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	if data.ValidateSubscriptions(v, preferences.Subscriptions, genres); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Notifications.UpdatePreferences(user.ID, preferences)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"notifications": preferences}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showUnsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if _, ok := app.unsubscribeUserID(token); !ok {
+		v := validator.New()
+		v.AddError("token", "invalid or expired unsubscribe token")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	err := unsubscribePage.Execute(w, token)
+	if err != nil {
+		app.logger.Error(err.Error())
+	}
+}
+
+func (app *application) unsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.unsubscribeUserID(r.URL.Query().Get("token"))
+	if !ok {
+		v := validator.New()
+		v.AddError("token", "invalid or expired unsubscribe token")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err := app.models.Notifications.DisableDigest(userID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "you have been unsubscribed from the weekly digest"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
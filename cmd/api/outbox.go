@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"greenlight.gustavosantos.net/internal/backoff"
+	"greenlight.gustavosantos.net/internal/data"
+	"greenlight.gustavosantos.net/internal/validator"
+)
+
+const (
+	outboxBatchSize   = 50
+	outboxLease       = 5 * time.Minute
+	outboxMaxAttempts = 10
+	outboxBaseBackoff = 10 * time.Second
+	outboxMaxBackoff  = time.Hour
+)
+
+type outboxHandler struct {
+	name   string
+	topics []string
+	handle func(ctx context.Context, tx *sql.Tx, entry *data.OutboxEntry) error
+}
+
+func (app *application) outboxHandlers() []outboxHandler {
+	return []outboxHandler{
+		{
+			name:   "log",
+			handle: app.logOutboxEntry,
+		},
+		{
+			name:   "mailer",
+			topics: []string{data.TopicUserRegistered, data.TopicActivationRequested, data.TopicPasswordResetRequested},
+			handle: app.mailOutboxEntry,
+		},
+		{
+			name:   "notifications",
+			topics: []string{data.EventMovieCreated},
+			handle: app.notifySubscribers,
+		},
+		{
+			name:   "webhooks",
+			topics: data.WebhookEvents,
+			handle: app.enqueueWebhookDeliveries,
+		},
+	}
+}
+
+func (app *application) logOutboxEntry(ctx context.Context, tx *sql.Tx, entry *data.OutboxEntry) error {
+	app.logger.Info("outbox entry published", "id", entry.ID, "topic", entry.Topic, "attempt", entry.Attempts)
+	return nil
+}
+
+func (app *application) mailOutboxEntry(ctx context.Context, tx *sql.Tx, entry *data.OutboxEntry) error {
+	var user data.User
+	err := json.Unmarshal(entry.Payload, &user)
+	if err != nil {
+		return err
+	}
+	_, err = app.jobs.EnqueueTx(ctx, tx, jobSendUserEmail, userEmailJob{Topic: entry.Topic, UserID: user.ID, Email: user.Email})
+	return err
+}
+
+func (app *application) notifySubscribers(ctx context.Context, tx *sql.Tx, entry *data.OutboxEntry) error {
+	var movie data.Movie
+	err := json.Unmarshal(entry.Payload, &movie)
+	if err != nil {
+		return err
+	}
+	_, err = app.models.Notifications.EnqueueForMovieTx(ctx, tx, &movie)
+	return err
+}
+
+func (app *application) enqueueWebhookDeliveries(ctx context.Context, tx *sql.Tx, entry *data.OutboxEntry) error {
+	return app.models.Webhooks.Enqueue(ctx, tx, entry.Topic, entry.Payload)
+}
+
+func (app *application) relayOutbox(ctx context.Context) error {
+	handlers := app.outboxHandlers()
+	for {
+		entries, err := app.models.Outbox.Claim(ctx, outboxBatchSize, outboxLease)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			app.publishOutboxEntry(ctx, handlers, entry)
+		}
+		if len(entries) < outboxBatchSize || ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+func (app *application) publishOutboxEntry(ctx context.Context, handlers []outboxHandler, entry *data.OutboxEntry) {
+	var errs []error
+	for _, handler := range handlers {
+		if handler.topics != nil && !slices.Contains(handler.topics, entry.Topic) {
+			continue
+		}
+		err := app.models.Outbox.Consume(ctx, entry.ID, handler.name, func(tx *sql.Tx) error {
+			return handler.handle(ctx, tx, entry)
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", handler.name, err))
+		}
+	}
+	var retryAfter time.Duration
+	lastError := ""
+	switch {
+	case len(errs) == 0:
+		entry.Status = data.OutboxStatusPublished
+	case entry.Attempts >= outboxMaxAttempts:
+		entry.Status = data.OutboxStatusFailed
+	default:
+		retryAfter = backoff.Exponential(entry.Attempts, outboxBaseBackoff, outboxMaxBackoff)
+	}
+	if len(errs) > 0 {
+		lastError = errors.Join(errs...).Error()
+		app.logger.Error(lastError, "job", "outbox", "outbox_id", entry.ID, "topic", entry.Topic)
+	}
+	err := app.models.Outbox.RecordAttempt(context.WithoutCancel(ctx), entry, lastError, retryAfter)
+	if err != nil {
+		app.logger.Error(err.Error(), "job", "outbox", "outbox_id", entry.ID)
+	}
+}
+
+func (app *application) listStuckOutboxEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	var filters data.Filters
+	v := validator.New()
+	qs := r.URL.Query()
+	olderThan, err := time.ParseDuration(app.readString(qs, "older_than", "5m"))
+	if err != nil {
+		v.AddError("older_than", "must be a duration such as 30s, 5m or 1h")
+	}
+	v.Check(olderThan >= 0, "older_than", "must not be negative")
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	filters.Sort = "id"
+	filters.SortSafelist = []string{"id"}
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	entries, metadata, err := app.models.Outbox.Stuck(olderThan, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"entries": entries, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) retryOutboxEntryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	err = app.models.Outbox.Retry(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"message": "outbox entry scheduled for retry"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
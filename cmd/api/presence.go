@@ -0,0 +1,441 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"greenlight.gustavosantos.net/internal/data"
+	"greenlight.gustavosantos.net/internal/websocket"
+)
+
+const (
+	presenceHeartbeat   = 20 * time.Second
+	presenceTTL         = time.Minute
+	presencePing        = 30 * time.Second
+	presenceReadTimeout = 75 * time.Second
+	presenceBuffer      = 16
+	presenceModeViewing = "viewing"
+	presenceModeEditing = "editing"
+	presenceProtocol    = "greenlight.presence.v1"
+)
+
+const websocketBearerPrefix = "bearer."
+
+type presenceEntry struct {
+	SessionID string    `json:"session_id"`
+	UserID    int64     `json:"user_id"`
+	Name      string    `json:"name"`
+	Mode      string    `json:"mode"`
+	Since     time.Time `json:"since"`
+	instance  string
+	seenAt    time.Time
+}
+
+type presenceMessage struct {
+	Type     string        `json:"type"`
+	Instance string        `json:"instance"`
+	MovieID  int64         `json:"movie_id"`
+	Entry    presenceEntry `json:"entry"`
+}
+
+type presenceSession struct {
+	movieID int64
+	entry   presenceEntry
+	send    chan any
+	conn    *websocket.Conn
+}
+
+type presenceHub struct {
+	instance string
+	notifier data.MovieEventModel
+	logger   *slog.Logger
+	mu       sync.Mutex
+	rosters  map[int64]map[string]*presenceEntry
+	sessions map[int64]map[*presenceSession]struct{}
+	closed   bool
+}
+
+func randomID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func newPresenceHub(notifier data.MovieEventModel, logger *slog.Logger) *presenceHub {
+	return &presenceHub{
+		instance: randomID(),
+		notifier: notifier,
+		logger:   logger,
+		rosters:  make(map[int64]map[string]*presenceEntry),
+		sessions: make(map[int64]map[*presenceSession]struct{}),
+	}
+}
+
+func (h *presenceHub) publish(ctx context.Context, messages ...presenceMessage) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 3*time.Second)
+	defer cancel()
+	for _, msg := range messages {
+		msg.Instance = h.instance
+		js, err := json.Marshal(msg)
+		if err != nil {
+			h.logger.Error(err.Error(), "job", "presence")
+			continue
+		}
+		err = h.notifier.Notify(ctx, data.MoviePresenceChannel, js)
+		if err != nil {
+			h.logger.Error(err.Error(), "job", "presence")
+		}
+	}
+}
+
+func (h *presenceHub) roster(movieID int64) []presenceEntry {
+	entries := make([]presenceEntry, 0, len(h.rosters[movieID]))
+	for _, entry := range h.rosters[movieID] {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Since.Before(entries[j].Since)
+	})
+	return entries
+}
+
+func (h *presenceHub) setEntry(movieID int64, entry presenceEntry) {
+	if h.rosters[movieID] == nil {
+		h.rosters[movieID] = make(map[string]*presenceEntry)
+	}
+	h.rosters[movieID][entry.SessionID] = &entry
+}
+
+func (h *presenceHub) deleteEntry(movieID int64, sessionID string) bool {
+	if _, ok := h.rosters[movieID][sessionID]; !ok {
+		return false
+	}
+	delete(h.rosters[movieID], sessionID)
+	if len(h.rosters[movieID]) == 0 {
+		delete(h.rosters, movieID)
+	}
+	return true
+}
+
+func (h *presenceHub) broadcastRoster(movieID int64) []presenceMessage {
+	var dropped []presenceMessage
+	msg := envelope{"type": "presence", "movie_id": movieID, "editors": h.roster(movieID)}
+	for session := range h.sessions[movieID] {
+		select {
+		case session.send <- msg:
+		default:
+			dropped = append(dropped, h.removeSession(session))
+		}
+	}
+	if len(dropped) > 0 {
+		dropped = append(dropped, h.broadcastRoster(movieID)...)
+	}
+	return dropped
+}
+
+func (h *presenceHub) removeSession(session *presenceSession) presenceMessage {
+	delete(h.sessions[session.movieID], session)
+	if len(h.sessions[session.movieID]) == 0 {
+		delete(h.sessions, session.movieID)
+	}
+	h.deleteEntry(session.movieID, session.entry.SessionID)
+	close(session.send)
+	return presenceMessage{Type: "leave", MovieID: session.movieID, Entry: session.entry}
+}
+
+func (h *presenceHub) join(ctx context.Context, conn *websocket.Conn, movieID int64, user *data.User) (*presenceSession, bool) {
+	now := time.Now()
+	session := &presenceSession{
+		movieID: movieID,
+		entry: presenceEntry{
+			SessionID: randomID(),
+			UserID:    user.ID,
+			Name:      user.Name,
+			Mode:      presenceModeViewing,
+			Since:     now,
+			instance:  h.instance,
+			seenAt:    now,
+		},
+		send: make(chan any, presenceBuffer),
+		conn: conn,
+	}
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil, false
+	}
+	if h.sessions[movieID] == nil {
+		h.sessions[movieID] = make(map[*presenceSession]struct{})
+	}
+	h.sessions[movieID][session] = struct{}{}
+	h.setEntry(movieID, session.entry)
+	messages := append([]presenceMessage{{Type: "join", MovieID: movieID, Entry: session.entry}}, h.broadcastRoster(movieID)...)
+	h.mu.Unlock()
+	h.publish(ctx, messages...)
+	return session, true
+}
+
+func (h *presenceHub) leave(ctx context.Context, session *presenceSession) {
+	h.mu.Lock()
+	if _, ok := h.sessions[session.movieID][session]; !ok {
+		h.mu.Unlock()
+		return
+	}
+	messages := append([]presenceMessage{h.removeSession(session)}, h.broadcastRoster(session.movieID)...)
+	h.mu.Unlock()
+	h.publish(ctx, messages...)
+}
+
+func (h *presenceHub) setMode(ctx context.Context, session *presenceSession, mode string) {
+	h.mu.Lock()
+	if _, ok := h.sessions[session.movieID][session]; !ok || session.entry.Mode == mode {
+		h.mu.Unlock()
+		return
+	}
+	session.entry.Mode = mode
+	h.setEntry(session.movieID, session.entry)
+	messages := append([]presenceMessage{{Type: "update", MovieID: session.movieID, Entry: session.entry}}, h.broadcastRoster(session.movieID)...)
+	h.mu.Unlock()
+	h.publish(ctx, messages...)
+}
+
+func (h *presenceHub) receive(ctx context.Context, payload []byte) {
+	var msg presenceMessage
+	err := json.Unmarshal(payload, &msg)
+	if err != nil {
+		h.logger.Error(err.Error(), "job", "presence")
+		return
+	}
+	if msg.Instance == h.instance {
+		return
+	}
+	var messages []presenceMessage
+	h.mu.Lock()
+	switch msg.Type {
+	case "join", "update", "heartbeat":
+		existing, known := h.rosters[msg.MovieID][msg.Entry.SessionID]
+		changed := !known || existing.Mode != msg.Entry.Mode
+		msg.Entry.instance = msg.Instance
+		msg.Entry.seenAt = time.Now()
+		h.setEntry(msg.MovieID, msg.Entry)
+		if msg.Type == "join" {
+			for session := range h.sessions[msg.MovieID] {
+				messages = append(messages, presenceMessage{Type: "heartbeat", MovieID: msg.MovieID, Entry: session.entry})
+			}
+		}
+		if changed {
+			messages = append(messages, h.broadcastRoster(msg.MovieID)...)
+		}
+	case "leave":
+		if h.deleteEntry(msg.MovieID, msg.Entry.SessionID) {
+			messages = append(messages, h.broadcastRoster(msg.MovieID)...)
+		}
+	}
+	h.mu.Unlock()
+	h.publish(ctx, messages...)
+}
+
+func (h *presenceHub) heartbeat(ctx context.Context) {
+	var messages []presenceMessage
+	expired := time.Now().Add(-presenceTTL)
+	h.mu.Lock()
+	for movieID, sessions := range h.sessions {
+		for session := range sessions {
+			messages = append(messages, presenceMessage{Type: "heartbeat", MovieID: movieID, Entry: session.entry})
+		}
+	}
+	for movieID, entries := range h.rosters {
+		changed := false
+		for sessionID, entry := range entries {
+			if entry.instance != h.instance && entry.seenAt.Before(expired) {
+				changed = h.deleteEntry(movieID, sessionID) || changed
+			}
+		}
+		if changed {
+			messages = append(messages, h.broadcastRoster(movieID)...)
+		}
+	}
+	h.mu.Unlock()
+	h.publish(ctx, messages...)
+}
+
+func (h *presenceHub) close() {
+	h.mu.Lock()
+	h.closed = true
+	var (
+		conns    []*websocket.Conn
+		messages []presenceMessage
+	)
+	for _, sessions := range h.sessions {
+		for session := range sessions {
+			conns = append(conns, session.conn)
+			messages = append(messages, h.removeSession(session))
+		}
+	}
+	h.mu.Unlock()
+	for _, conn := range conns {
+		go func(conn *websocket.Conn) {
+			conn.CloseWith(websocket.CloseGoingAway, "server shutting down")
+			conn.Close()
+		}(conn)
+	}
+	h.publish(context.Background(), messages...)
+}
+
+func (app *application) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || slices.Contains(app.config.cors.trustedOrigins, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+func (app *application) moviePresenceHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if !app.originAllowed(r) {
+		app.errorResponse(w, r, http.StatusForbidden, "origin not allowed")
+		return
+	}
+	user := app.contextGetUser(r)
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	canEdit := permissions.Include("movies:write")
+	events, unsubscribe, ok := app.events.subscribe()
+	if !ok {
+		app.errorResponse(w, r, http.StatusServiceUnavailable, "the server is shutting down, please retry later")
+		return
+	}
+	defer unsubscribe()
+	app.wg.Add(1)
+	defer app.wg.Done()
+	conn, err := websocket.Upgrade(w, r, []string{presenceProtocol})
+	if err != nil {
+		switch {
+		case errors.Is(err, websocket.ErrBadHandshake):
+			app.errorResponse(w, r, http.StatusBadRequest, "this endpoint requires a websocket upgrade request")
+		default:
+			app.logger.Error(err.Error(), "stream", "presence")
+		}
+		return
+	}
+	defer conn.Close()
+	conn.ReadTimeout = presenceReadTimeout
+	session, ok := app.presence.join(r.Context(), conn, movie.ID, user)
+	if !ok {
+		conn.CloseWith(websocket.CloseGoingAway, "server shutting down")
+		return
+	}
+	defer app.presence.leave(r.Context(), session)
+	done := make(chan struct{})
+	defer close(done)
+	incoming := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			op, msg, err := conn.ReadMessage()
+			if err != nil {
+				readErr <- err
+				return
+			}
+			if op != websocket.OpText {
+				continue
+			}
+			select {
+			case incoming <- msg:
+			case <-done:
+				return
+			}
+		}
+	}()
+	err = conn.WriteJSON(envelope{
+		"type":       "hello",
+		"session_id": session.entry.SessionID,
+		"movie_id":   movie.ID,
+		"version":    movie.Version,
+		"can_edit":   canEdit,
+	})
+	if err != nil {
+		return
+	}
+	ping := time.NewTicker(presencePing)
+	defer ping.Stop()
+	for {
+		select {
+		case <-readErr:
+			return
+		case msg := <-incoming:
+			var input struct {
+				Type string `json:"type"`
+				Mode string `json:"mode"`
+			}
+			err = json.Unmarshal(msg, &input)
+			switch {
+			case err != nil || input.Type != "mode":
+				err = conn.WriteJSON(envelope{"type": "error", "error": "messages must be of the form {\"type\": \"mode\", \"mode\": \"viewing|editing\"}"})
+			case input.Mode != presenceModeViewing && input.Mode != presenceModeEditing:
+				err = conn.WriteJSON(envelope{"type": "error", "error": "mode must be viewing or editing"})
+			case input.Mode == presenceModeEditing && !canEdit:
+				err = conn.WriteJSON(envelope{"type": "error", "error": "your user account doesn't have the necessary permissions to edit this movie"})
+			default:
+				app.presence.setMode(r.Context(), session, input.Mode)
+			}
+		case msg, ok := <-session.send:
+			if !ok {
+				conn.CloseWith(websocket.CloseGoingAway, "")
+				return
+			}
+			err = conn.WriteJSON(msg)
+		case event, ok := <-events:
+			if !ok {
+				conn.CloseWith(websocket.CloseGoingAway, "")
+				return
+			}
+			if event.MovieID != movie.ID {
+				continue
+			}
+			err = conn.WriteJSON(envelope{
+				"type":      "version",
+				"movie_id":  event.MovieID,
+				"version":   event.Version,
+				"operation": event.Operation,
+			})
+			if err == nil && event.Operation == "deleted" {
+				conn.CloseWith(websocket.CloseNormal, "movie deleted")
+				return
+			}
+		case <-ping.C:
+			err = conn.Ping()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
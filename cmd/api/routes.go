@@ -17,12 +17,70 @@ func (app *application) routes() http.Handler {
 	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.requirePermission("movies:read", app.showMovieHandler))
 	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.requirePermission("movies:write", app.updateMovieHandler))
 	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.requirePermission("movies:write", app.deleteMovieHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/images", app.requirePermission("movies:write", app.uploadMovieImageHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/similar", app.requirePermission("movies:read", app.listSimilarMoviesHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/translations", app.requirePermission("movies:read", app.listMovieTranslationsHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id/translations/:locale", app.requirePermission("movies:write", app.putMovieTranslationHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/translations/:locale", app.requirePermission("movies:write", app.deleteMovieTranslationHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/genres", app.requirePermission("movies:read", app.listGenresHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/genres", app.requirePermission("admin", app.createGenreHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/genres/merge", app.requirePermission("admin", app.mergeGenresHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/genres/:id", app.requirePermission("movies:read", app.showGenreHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/genres/:id", app.requirePermission("admin", app.updateGenreHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/genres/:id", app.requirePermission("admin", app.deleteGenreHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/events/movies", app.requirePermission("movies:read", app.movieEventsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/presence", app.authenticateWebSocket(app.requirePermission("movies:read", app.moviePresenceHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/collections", app.requirePermission("movies:read", app.listCollectionsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/collections", app.requirePermission("movies:write", app.createCollectionHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/collections/:id", app.requirePermission("movies:read", app.showCollectionHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/collections/:id", app.requirePermission("movies:write", app.updateCollectionHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/collections/:id", app.requirePermission("movies:write", app.deleteCollectionHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/movies/duplicates", app.requirePermission("admin", app.listDuplicateMoviesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/movies/merge", app.requirePermission("admin", app.mergeMoviesHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/scheduler", app.requirePermission("admin", app.showSchedulerHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/outbox", app.requirePermission("admin", app.listStuckOutboxEntriesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/outbox/:id/retry", app.requirePermission("admin", app.retryOutboxEntryHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/webhooks", app.requirePermission("admin", app.listWebhooksHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/webhooks", app.requirePermission("admin", app.createWebhookHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/webhooks/:id", app.requirePermission("admin", app.showWebhookHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/webhooks/:id", app.requirePermission("admin", app.updateWebhookHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/webhooks/:id", app.requirePermission("admin", app.deleteWebhookHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/webhooks/:id/deliveries", app.requirePermission("admin", app.listWebhookDeliveriesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/webhooks/:id/deliveries/:delivery_id/redeliver", app.requirePermission("admin", app.redeliverWebhookHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/stats/movies", app.requirePermission("movies:read", app.movieStatsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/search/movies", app.requirePermission("movies:read", app.searchMoviesHandler))
 	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/users/password", app.updateUserPasswordHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/users/me/history", app.requireActivatedUser(app.createWatchHistoryHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/recommendations", app.requireActivatedUser(app.listRecommendationsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/notifications", app.requireActivatedUser(app.showNotificationPreferencesHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/users/me/notifications", app.requireActivatedUser(app.updateNotificationPreferencesHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/notifications/unsubscribe", app.showUnsubscribeHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/notifications/unsubscribe", app.unsubscribeHandler)
 	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
 	router.HandlerFunc(http.MethodPost, "/v1/tokens/activation", app.createActivationTokenHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/tokens/password-reset", app.createPasswordResetTokenHandler)
 	router.Handler(http.MethodGet, "/debug/vars", expvar.Handler())
-	return app.metrics(app.recoverPanic(app.enableCORS(app.rateLimit(app.authenticate(router)))))
+	if app.config.storage.backend == "local" {
+		router.ServeFiles("/images/*filepath", http.Dir(app.config.storage.localDir))
+	}
+	staticRouter := httprouter.New()
+	staticRouter.HandlerFunc(http.MethodPost, "/v1/movies/import", app.requirePermission("movies:write", app.importMoviesHandler))
+	staticRouter.HandlerFunc(http.MethodGet, "/v1/movies/export", app.requirePermission("movies:read", app.exportMoviesHandler))
+	staticRouter.HandlerFunc(http.MethodGet, "/v1/movies/autocomplete", app.requirePermission("movies:read", app.autocompleteMoviesHandler))
+	staticRouter.HandlerFunc(http.MethodGet, "/v1/movies/trending", app.requirePermission("movies:read", app.listTrendingMoviesHandler))
+	staticRouter.HandlerFunc(http.MethodGet, "/v1/movies/recent", app.requirePermission("movies:read", app.listRecentMoviesHandler))
+	staticRouter.HandlerFunc(http.MethodGet, "/v1/movies/by-external-id/:source/:id", app.requirePermission("movies:read", app.showMovieByExternalIDHandler))
+	return app.metrics(app.recoverPanic(app.enableCORS(app.rateLimit(app.authenticate(preferStatic(staticRouter, router))))))
+}
+
+func preferStatic(static, fallback *httprouter.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handle, _, _ := static.Lookup(r.Method, r.URL.Path); handle != nil {
+			static.ServeHTTP(w, r)
+			return
+		}
+		fallback.ServeHTTP(w, r)
+	})
 }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"greenlight.gustavosantos.net/internal/scheduler"
+)
+
+func (app *application) scheduleTasks() error {
+	tasks := []struct {
+		name    string
+		spec    string
+		jitter  time.Duration
+		timeout time.Duration
+		fn      func(ctx context.Context) (int64, error)
+	}{
+		{"purge-expired-tokens", "@hourly", 5 * time.Minute, time.Minute, app.models.Tokens.DeleteExpired},
+		{"purge-movie-events", "15 * * * *", 5 * time.Minute, time.Minute, func(ctx context.Context) (int64, error) {
+			return app.models.MovieEvents.DeleteOlderThan(ctx, 24*time.Hour)
+		}},
+		{"purge-published-outbox", "30 3 * * *", 10 * time.Minute, 5 * time.Minute, func(ctx context.Context) (int64, error) {
+			return app.models.Outbox.DeletePublished(ctx, 7*24*time.Hour)
+		}},
+		{"purge-finished-jobs", "45 3 * * *", 10 * time.Minute, 5 * time.Minute, func(ctx context.Context) (int64, error) {
+			return app.jobs.Purge(ctx, 7*24*time.Hour)
+		}},
+	}
+	refreshes := []struct {
+		name    string
+		spec    string
+		jitter  time.Duration
+		timeout time.Duration
+		fn      scheduler.TaskFunc
+	}{
+		{"refresh-popularity", app.config.trending.refreshSchedule, 30 * time.Second, time.Minute, app.refreshPopularity},
+		{"refresh-recommendations", app.config.recommendations.schedule, 5 * time.Minute, 10 * time.Minute, app.refreshRecommendations},
+		{"send-digests", app.config.digest.schedule, 5 * time.Minute, 30 * time.Minute, app.sendDigests},
+	}
+	for _, task := range refreshes {
+		if task.spec == "" {
+			continue
+		}
+		err := app.scheduler.Add(task.name, task.spec, task.jitter, task.timeout, task.fn)
+		if err != nil {
+			return err
+		}
+	}
+	for _, task := range tasks {
+		name, fn := task.name, task.fn
+		err := app.scheduler.Add(name, task.spec, task.jitter, task.timeout, func(ctx context.Context) error {
+			deleted, err := fn(ctx)
+			if err != nil {
+				return err
+			}
+			app.logger.Info("purged rows", "task", name, "rows", deleted)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (app *application) startScheduler(ctx context.Context) {
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		app.scheduler.Run(ctx)
+	}()
+}
+
+func (app *application) showSchedulerHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+	tasks, err := app.scheduler.Status(ctx)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"enabled": app.config.scheduler.enabled, "tasks": tasks}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
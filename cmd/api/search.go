@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"greenlight.gustavosantos.net/internal/data"
+	"greenlight.gustavosantos.net/internal/validator"
+)
+
+func (app *application) searchMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Query    string
+		Language string
+		data.MovieCriteria
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.Query = app.readString(qs, "q", "")
+	input.Language = app.readString(qs, "lang", "en")
+	input.MovieCriteria = app.readMovieCriteria(qs, v)
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-rank")
+	input.Filters.SortSafelist = []string{
+		"-rank",
+		"title",
+		"-title",
+		"year",
+		"-year",
+		"runtime",
+		"-runtime",
+	}
+	data.ValidateSearch(v, input.Query, input.Language)
+	data.ValidateMovieCriteria(v, input.MovieCriteria)
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	results, metadata, searchErr := app.models.Movies.Search(input.Query, input.Language, input.MovieCriteria, input.Filters)
+	if searchErr != nil {
+		app.serverErrorResponse(w, r, searchErr)
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Link", app.paginationLinks(r, input.Filters, metadata))
+	writeJsonErr := app.writeJSON(w, http.StatusOK, envelope{"results": results, "metadata": metadata}, headers)
+	if writeJsonErr != nil {
+		app.serverErrorResponse(w, r, writeJsonErr)
+	}
+}
+
+func (app *application) autocompleteMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+	prefix := strings.TrimSpace(app.readString(qs, "q", ""))
+	limit := app.readInt(qs, "limit", 10, v)
+	v.Check(prefix != "", "q", "must be provided")
+	v.Check(len(prefix) <= 100, "q", "must not be more than 100 bytes long")
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 20, "limit", "must be a maximum of 20")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	suggestions, err := app.models.Movies.Suggest(prefix, limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Cache-Control", "private, max-age=60")
+	err = app.writeJSON(w, http.StatusOK, envelope{"suggestions": suggestions}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -21,6 +21,11 @@ func (app *application) serve() error {
 		WriteTimeout: 10 * time.Second,
 		ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
 	}
+	srv.RegisterOnShutdown(app.events.close)
+	srv.RegisterOnShutdown(app.presence.close)
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	app.startWorkers(workersCtx)
 	shutdownError := make(chan error)
 	go func() {
 		quit := make(chan os.Signal, 1)
@@ -34,6 +39,11 @@ func (app *application) serve() error {
 			shutdownError <- err
 		}
 		app.logger.Info("completing background tasks", "addr", srv.Addr)
+		stopWorkers()
+		err = app.jobs.Drain(ctx)
+		if err != nil {
+			app.logger.Warn("background jobs still running at shutdown", "error", err.Error())
+		}
 		app.wg.Wait()
 		shutdownError <- nil
 	}()
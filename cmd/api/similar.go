@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"greenlight.gustavosantos.net/internal/data"
+	"greenlight.gustavosantos.net/internal/validator"
+)
+
+const (
+	similarCandidateLimit = 500
+	similarCacheTTL       = 10 * time.Minute
+	similarCacheSize      = 1000
+)
+
+type similarCacheEntry struct {
+	similar []*data.SimilarMovie
+	expires time.Time
+}
+
+type similarCache struct {
+	mu      sync.Mutex
+	entries map[int64]similarCacheEntry
+}
+
+func newSimilarCache() *similarCache {
+	return &similarCache{entries: make(map[int64]similarCacheEntry)}
+}
+
+func (c *similarCache) get(movieID int64) ([]*data.SimilarMovie, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[movieID]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.similar, true
+}
+
+func (c *similarCache) set(movieID int64, similar []*data.SimilarMovie) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= similarCacheSize {
+		for id, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, id)
+			}
+		}
+	}
+	if len(c.entries) >= similarCacheSize {
+		for id := range c.entries {
+			delete(c.entries, id)
+			break
+		}
+	}
+	c.entries[movieID] = similarCacheEntry{similar: similar, expires: now.Add(similarCacheTTL)}
+}
+
+func (c *similarCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[int64]similarCacheEntry)
+}
+
+func (app *application) similarMovies(movie *data.Movie) ([]*data.SimilarMovie, error) {
+	if similar, ok := app.similar.get(movie.ID); ok {
+		return similar, nil
+	}
+	candidates, err := app.models.Movies.SimilarCandidates(movie, similarCandidateLimit)
+	if err != nil {
+		return nil, err
+	}
+	similar := make([]*data.SimilarMovie, len(candidates))
+	for i, candidate := range candidates {
+		similar[i] = &data.SimilarMovie{Movie: candidate, Score: app.scorer.Score(movie, candidate)}
+	}
+	sort.SliceStable(similar, func(i, j int) bool {
+		return similar[i].Score > similar[j].Score
+	})
+	app.similar.set(movie.ID, similar)
+	return similar, nil
+}
+
+func (app *application) listSimilarMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var input struct {
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 10, v)
+	input.Filters.Sort = "id"
+	input.Filters.SortSafelist = []string{"id"}
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	similar, err := app.similarMovies(movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	page, metadata := data.PaginateSimilar(similar, input.Filters)
+	results := make([]*data.SimilarMovie, len(page))
+	movies := make([]*data.Movie, len(page))
+	for i, s := range page {
+		copied := *s.Movie
+		movies[i] = &copied
+		results[i] = &data.SimilarMovie{Movie: &copied, Score: s.Score}
+	}
+	err = app.attachImages(movies...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.models.MovieTranslations.Localize(app.readLocales(r), movies...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Vary", "Accept-Language")
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": results, "metadata": metadata}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
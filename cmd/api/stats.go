@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"greenlight.gustavosantos.net/internal/data"
+	"greenlight.gustavosantos.net/internal/validator"
+)
+
+func (app *application) movieStatsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.MovieCriteria
+		Format string
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.MovieCriteria = app.readMovieCriteria(qs, v)
+	input.Format = app.readString(qs, "format", "json")
+	data.ValidateMovieCriteria(v, input.MovieCriteria)
+	if v.Check(validator.PermittedValue(input.Format, "csv", "json"), "format", "invalid format value"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	stats, err := app.models.Movies.Stats(input.MovieCriteria)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if input.Format == "json" {
+		err = app.writeJSON(w, http.StatusOK, envelope{"stats": stats}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	formatFloat := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', 2, 64)
+	}
+	records := [][]string{
+		{"metric", "key", "value"},
+		{"total", "", strconv.Itoa(stats.Total)},
+		{"average_runtime", "", formatFloat(stats.AverageRuntime)},
+		{"runtime_percentile", "p25", formatFloat(stats.RuntimePercentiles.P25)},
+		{"runtime_percentile", "p50", formatFloat(stats.RuntimePercentiles.P50)},
+		{"runtime_percentile", "p75", formatFloat(stats.RuntimePercentiles.P75)},
+		{"runtime_percentile", "p90", formatFloat(stats.RuntimePercentiles.P90)},
+		{"recently_added", "last_7_days", strconv.Itoa(stats.RecentlyAdded.Last7Days)},
+		{"recently_added", "last_30_days", strconv.Itoa(stats.RecentlyAdded.Last30Days)},
+		{"recently_added", "last_365_days", strconv.Itoa(stats.RecentlyAdded.Last365Days)},
+	}
+	for _, group := range []struct {
+		metric string
+		counts []data.FacetCount
+	}{
+		{"year", stats.Years},
+		{"decade", stats.Decades},
+		{"genre", stats.Genres},
+	} {
+		for _, count := range group.counts {
+			records = append(records, []string{group.metric, count.Value, strconv.Itoa(count.Count)})
+		}
+	}
+	filename := fmt.Sprintf("movie-stats-%s.csv", time.Now().UTC().Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	writer := csv.NewWriter(w)
+	err = writer.WriteAll(records)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
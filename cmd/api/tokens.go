@@ -143,20 +143,11 @@ func (app *application) createPasswordResetTokenHandler(w http.ResponseWriter, r
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	token, err := app.models.Tokens.New(user.ID, 45*time.Minute, data.ScopePasswordReset)
+	err = app.models.Outbox.Publish(data.TopicPasswordResetRequested, user)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
-	app.background(func() {
-		data := map[string]any{
-			"passwordResetToken": token.Plaintext,
-		}
-		err = app.mailer.Send(user.Email, "token_password_reset.tmpl", data)
-		if err != nil {
-			app.logger.Error(err.Error())
-		}
-	})
 	env := envelope{"message": "an email will be sent to you containing password reset instructions"}
 	err = app.writeJSON(w, http.StatusAccepted, env, nil)
 	if err != nil {
@@ -194,20 +185,11 @@ func (app *application) createActivationTokenHandler(w http.ResponseWriter, r *h
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	token, err := app.models.Tokens.New(user.ID, 3*24*time.Hour, data.ScopeActivation)
+	err = app.models.Outbox.Publish(data.TopicActivationRequested, user)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
-	app.background(func() {
-		data := map[string]any{
-			"activationToken": token.Plaintext,
-		}
-		err = app.mailer.Send(user.Email, "token_activation.tmpl", data)
-		if err != nil {
-			app.logger.Error(err.Error())
-		}
-	})
 	env := envelope{"message": "an email will be sent to you containing activation instructions"}
 	err = app.writeJSON(w, http.StatusAccepted, env, nil)
 	if err != nil {
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"greenlight.gustavosantos.net/internal/data"
+	"greenlight.gustavosantos.net/internal/validator"
+)
+
+type viewCounter struct {
+	mu     sync.Mutex
+	counts map[int64]int64
+}
+
+func newViewCounter() *viewCounter {
+	return &viewCounter{counts: make(map[int64]int64)}
+}
+
+func (c *viewCounter) record(movieID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[movieID]++
+}
+
+func (c *viewCounter) drain() map[int64]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := c.counts
+	c.counts = make(map[int64]int64)
+	return counts
+}
+
+func (c *viewCounter) restore(counts map[int64]int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for movieID, n := range counts {
+		c.counts[movieID] += n
+	}
+}
+
+func (app *application) flushViews(ctx context.Context) error {
+	counts := app.views.drain()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	err := app.models.Popularity.RecordViews(ctx, counts, time.Now())
+	if err != nil {
+		app.views.restore(counts)
+		return err
+	}
+	return nil
+}
+
+func (app *application) startViewFlusher(ctx context.Context) {
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		ticker := time.NewTicker(app.config.trending.flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				err := app.flushViews(context.Background())
+				if err != nil {
+					app.logger.Error(err.Error(), "job", "views")
+				}
+				return
+			case <-ticker.C:
+				err := app.flushViews(ctx)
+				if err != nil {
+					app.logger.Error(err.Error(), "job", "views")
+				}
+			}
+		}
+	}()
+}
+
+func (app *application) refreshPopularity(ctx context.Context) error {
+	return app.models.Popularity.Refresh(ctx)
+}
+
+func (app *application) listTrendingMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Window string
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.Window = app.readString(qs, "window", "week")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "id"
+	input.Filters.SortSafelist = []string{"id"}
+	_, knownWindow := data.TrendingWindows[input.Window]
+	v.Check(knownWindow, "window", "must be day or week")
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	movies, metadata, err := app.models.Popularity.Trending(input.Window, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.writeMovieFeed(w, r, movies, metadata)
+}
+
+func (app *application) listRecentMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		By string
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.By = app.readString(qs, "by", "added")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "id"
+	input.Filters.SortSafelist = []string{"id"}
+	v.Check(validator.PermittedValue(input.By, "added", "updated"), "by", "must be added or updated")
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	movies, metadata, err := app.models.Popularity.Recent(input.By == "updated", input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.writeMovieFeed(w, r, movies, metadata)
+}
+
+func (app *application) writeMovieFeed(w http.ResponseWriter, r *http.Request, movies []*data.Movie, metadata data.Metadata) {
+	err := app.attachImages(movies...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.models.MovieTranslations.Localize(app.readLocales(r), movies...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Vary", "Accept-Language")
+	headers.Set("Cache-Control", "private, max-age=60")
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"errors"
 	"net/http"
-	"time"
 
 	"greenlight.gustavosantos.net/internal/data"
 	"greenlight.gustavosantos.net/internal/validator"
@@ -35,37 +34,17 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	insertErr := app.models.Users.Insert(user)
-	if insertErr != nil {
+	registerErr := app.models.Users.Register(user, "movies:read")
+	if registerErr != nil {
 		switch {
-		case errors.Is(insertErr, data.ErrDuplicateEmail):
+		case errors.Is(registerErr, data.ErrDuplicateEmail):
 			v.AddError("email", "a user with this email address already exists")
 			app.failedValidationResponse(w, r, v.Errors)
 		default:
-			app.serverErrorResponse(w, r, insertErr)
+			app.serverErrorResponse(w, r, registerErr)
 		}
 		return
 	}
-	permissionError := app.models.Permissions.AddForUser(user.ID, "movies:read")
-	if permissionError != nil {
-		app.serverErrorResponse(w, r, permissionError)
-		return
-	}
-	token, tokenErr := app.models.Tokens.New(user.ID, 3*24*time.Hour, data.ScopeActivation)
-	if tokenErr != nil {
-		app.serverErrorResponse(w, r, tokenErr)
-		return
-	}
-	app.background(func() {
-		data := map[string]any{
-			"activationToken": token.Plaintext,
-			"userID":          user.ID,
-		}
-		sendEmailErr := app.mailer.Send(user.Email, "user_welcome.tmpl", data)
-		if sendEmailErr != nil {
-			app.logger.Error(sendEmailErr.Error())
-		}
-	})
 	writeJsonErr := app.writeJSON(w, http.StatusAccepted, envelope{"user": user}, nil)
 	if writeJsonErr != nil {
 		app.serverErrorResponse(w, r, writeJsonErr)
@@ -97,22 +76,16 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		}
 		return
 	}
-	user.Activated = true
-	updateUserErr := app.models.Users.Update(user)
-	if updateUserErr != nil {
+	activateUserErr := app.models.Users.Activate(user)
+	if activateUserErr != nil {
 		switch {
-		case errors.Is(updateUserErr, data.ErrEditConflict):
+		case errors.Is(activateUserErr, data.ErrEditConflict):
 			app.editConflictResponse(w, r)
 		default:
-			app.serverErrorResponse(w, r, updateUserErr)
+			app.serverErrorResponse(w, r, activateUserErr)
 		}
 		return
 	}
-	deleteTokenErr := app.models.Tokens.DeleteAllForUser(data.ScopeActivation, user.ID)
-	if deleteTokenErr != nil {
-		app.serverErrorResponse(w, r, deleteTokenErr)
-		return
-	}
 	writeJsonErr := app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
 	if writeJsonErr != nil {
 		app.serverErrorResponse(w, r, writeJsonErr)
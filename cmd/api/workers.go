@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+func (app *application) startWorkers(ctx context.Context) {
+	app.jobs.Start(ctx)
+	app.startViewFlusher(ctx)
+	app.startNotificationListener(ctx)
+	if app.config.scheduler.enabled {
+		app.startScheduler(ctx)
+	}
+	app.every(ctx, app.config.outbox.interval, "outbox", app.relayOutbox)
+	if app.config.webhooks.interval > 0 {
+		app.every(ctx, app.config.webhooks.interval, "webhooks", app.deliverWebhooks)
+	}
+}
+
+func (app *application) refreshRecommendations(ctx context.Context) error {
+	started := time.Now()
+	inserted, err := app.models.Recommendations.Refresh(ctx, app.config.recommendations.perUser)
+	if err != nil {
+		return err
+	}
+	app.logger.Info("refreshed recommendations", "rows", inserted, "duration", time.Since(started).String())
+	return nil
+}
@@ -0,0 +1,11 @@
+package backoff
+
+import "time"
+
+func Exponential(attempts int, base, limit time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
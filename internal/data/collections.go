@@ -0,0 +1,315 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.gustavosantos.net/internal/validator"
+)
+
+var (
+	ErrMovieInCollection = errors.New("movie already belongs to a collection")
+	ErrUnknownMovie      = errors.New("unknown movie")
+)
+
+type Collection struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"-"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	MovieIDs    []int64   `json:"movie_ids"`
+	Version     int32     `json:"version"`
+}
+
+type CollectionSummary struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Position int    `json:"position"`
+	Size     int    `json:"size"`
+}
+
+func ValidateCollection(v *validator.Validator, collection *Collection) {
+	v.Check(collection.Name != "", "name", "must be provided")
+	v.Check(len(collection.Name) <= 200, "name", "must not be more than 200 bytes long")
+	v.Check(len(collection.Description) <= 5000, "description", "must not be more than 5000 bytes long")
+	v.Check(collection.MovieIDs != nil, "movie_ids", "must be provided")
+	v.Check(len(collection.MovieIDs) <= 100, "movie_ids", "must not contain more than 100 movies")
+	v.Check(validator.Unique(collection.MovieIDs), "movie_ids", "must not contain duplicate values")
+	for _, id := range collection.MovieIDs {
+		v.Check(id > 0, "movie_ids", "must only contain positive integers")
+	}
+}
+
+type CollectionModel struct {
+	DB *sql.DB
+}
+
+func (m CollectionModel) GetAll(name string, filters Filters) ([]*Collection, Metadata, error) {
+	query := fmt.Sprintf(`
+        SELECT
+            count(*) OVER(),
+            id,
+            created_at,
+            name,
+            description,
+            ARRAY(
+                SELECT movie_id
+                FROM collection_movies
+                WHERE collection_id = collections.id
+                ORDER BY position
+            ),
+            version
+        FROM
+            collections
+        WHERE
+            (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+        ORDER BY
+            %s
+        LIMIT $2
+        OFFSET $3
+    `, filters.orderBy(false))
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, name, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	collections := []*Collection{}
+	for rows.Next() {
+		var collection Collection
+		err := rows.Scan(
+			&totalRecords,
+			&collection.ID,
+			&collection.CreatedAt,
+			&collection.Name,
+			&collection.Description,
+			pq.Array(&collection.MovieIDs),
+			&collection.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		collections = append(collections, &collection)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return collections, metadata, nil
+}
+
+func (m CollectionModel) Get(id int64) (*Collection, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+        SELECT
+            id,
+            created_at,
+            name,
+            description,
+            ARRAY(
+                SELECT movie_id
+                FROM collection_movies
+                WHERE collection_id = collections.id
+                ORDER BY position
+            ),
+            version
+        FROM
+            collections
+        WHERE
+            id = $1
+    `
+	var collection Collection
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&collection.ID,
+		&collection.CreatedAt,
+		&collection.Name,
+		&collection.Description,
+		pq.Array(&collection.MovieIDs),
+		&collection.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &collection, nil
+}
+
+func (m CollectionModel) GetSummaryForMovie(movieID int64) (*CollectionSummary, error) {
+	query := `
+        SELECT
+            collections.id,
+            collections.name,
+            collection_movies.position,
+            (SELECT count(*) FROM collection_movies AS members WHERE members.collection_id = collections.id)
+        FROM
+            collection_movies
+            INNER JOIN collections ON collections.id = collection_movies.collection_id
+        WHERE
+            collection_movies.movie_id = $1
+    `
+	var summary CollectionSummary
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, movieID).Scan(
+		&summary.ID,
+		&summary.Name,
+		&summary.Position,
+		&summary.Size,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &summary, nil
+}
+
+func (m CollectionModel) Insert(collection *Collection) error {
+	query := `
+        INSERT INTO collections (
+            name,
+            description
+        )
+        VALUES (
+            $1,
+            $2
+        )
+        RETURNING
+            id,
+            created_at,
+            version
+    `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = tx.QueryRowContext(ctx, query, collection.Name, collection.Description).Scan(
+		&collection.ID,
+		&collection.CreatedAt,
+		&collection.Version,
+	)
+	if err != nil {
+		return err
+	}
+	err = setCollectionMovies(ctx, tx, collection)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m CollectionModel) Update(collection *Collection) error {
+	query := `
+        UPDATE
+            collections
+        SET
+            name = $1,
+            description = $2,
+            version = version + 1
+        WHERE
+            id = $3
+            AND version = $4
+        RETURNING
+            version
+    `
+	args := []any{collection.Name, collection.Description, collection.ID, collection.Version}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&collection.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	err = setCollectionMovies(ctx, tx, collection)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func setCollectionMovies(ctx context.Context, tx *sql.Tx, collection *Collection) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM collection_movies WHERE collection_id = $1`, collection.ID)
+	if err != nil {
+		return err
+	}
+	query := `
+        INSERT INTO collection_movies (
+            collection_id,
+            movie_id,
+            position
+        )
+        SELECT
+            $1,
+            member.movie_id,
+            member.position
+        FROM
+            unnest($2::bigint[]) WITH ORDINALITY AS member(movie_id, position)
+    `
+	_, err = tx.ExecContext(ctx, query, collection.ID, pq.Array(collection.MovieIDs))
+	if err != nil {
+		switch {
+		case violatesConstraint(err, uniqueViolation, "collection_movies_pkey"):
+			return ErrMovieInCollection
+		case violatesConstraint(err, foreignKeyViolation, "collection_movies_movie_id_fkey"):
+			return ErrUnknownMovie
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m CollectionModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `
+        DELETE FROM
+            collections
+        WHERE
+            id = $1
+    `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
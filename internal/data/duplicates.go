@@ -0,0 +1,247 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const normalizedTitle = `trim(regexp_replace(lower(%s.title), '[^[:alnum:]]+', ' ', 'g'))`
+
+type MovieDuplicate struct {
+	Movie      *Movie  `json:"movie"`
+	Duplicate  *Movie  `json:"duplicate"`
+	Similarity float64 `json:"similarity"`
+}
+
+type PossibleDuplicate struct {
+	*Movie
+	Similarity float64 `json:"similarity"`
+}
+
+func (m MovieModel) Duplicates(threshold float64, filters Filters) ([]*MovieDuplicate, Metadata, error) {
+	similarity := fmt.Sprintf("similarity(%s, %s)", fmt.Sprintf(normalizedTitle, "a"), fmt.Sprintf(normalizedTitle, "b"))
+	query := fmt.Sprintf(`
+        SELECT
+            count(*) OVER(),
+            a.id,
+            a.title,
+            a.year,
+            a.runtime,
+            a.version,
+            b.id,
+            b.title,
+            b.year,
+            b.runtime,
+            b.version,
+            %[1]s AS score
+        FROM
+            movies AS a
+            INNER JOIN movies AS b ON a.id < b.id
+                AND b.year BETWEEN a.year - 1 AND a.year + 1
+                AND abs(b.runtime - a.runtime) <= 5
+        WHERE
+            %[1]s >= $1
+        ORDER BY
+            score DESC,
+            a.id ASC,
+            b.id ASC
+        LIMIT $2
+        OFFSET $3
+    `, similarity)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, threshold, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	duplicates := []*MovieDuplicate{}
+	for rows.Next() {
+		duplicate := MovieDuplicate{Movie: &Movie{}, Duplicate: &Movie{}}
+		err := rows.Scan(
+			&totalRecords,
+			&duplicate.Movie.ID,
+			&duplicate.Movie.Title,
+			&duplicate.Movie.Year,
+			&duplicate.Movie.Runtime,
+			&duplicate.Movie.Version,
+			&duplicate.Duplicate.ID,
+			&duplicate.Duplicate.Title,
+			&duplicate.Duplicate.Year,
+			&duplicate.Duplicate.Runtime,
+			&duplicate.Duplicate.Version,
+			&duplicate.Similarity,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		duplicates = append(duplicates, &duplicate)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return duplicates, metadata, nil
+}
+
+func (m MovieModel) PossibleDuplicates(movie *Movie, threshold float64) ([]*PossibleDuplicate, error) {
+	similarity := fmt.Sprintf("similarity(%s, trim(regexp_replace(lower($1), '[^[:alnum:]]+', ' ', 'g')))", fmt.Sprintf(normalizedTitle, "movies"))
+	query := fmt.Sprintf(`
+        SELECT
+            id,
+            title,
+            year,
+            runtime,
+            version,
+            %[1]s AS score
+        FROM
+            movies
+        WHERE
+            id <> $2
+            AND year BETWEEN $3::integer - 1 AND $3::integer + 1
+            AND abs(runtime - $4) <= 5
+            AND %[1]s >= $5
+        ORDER BY
+            score DESC,
+            id ASC
+        LIMIT 5
+    `, similarity)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, movie.Title, movie.ID, movie.Year, movie.Runtime, threshold)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	duplicates := []*PossibleDuplicate{}
+	for rows.Next() {
+		duplicate := PossibleDuplicate{Movie: &Movie{}}
+		err := rows.Scan(
+			&duplicate.ID,
+			&duplicate.Title,
+			&duplicate.Year,
+			&duplicate.Runtime,
+			&duplicate.Version,
+			&duplicate.Similarity,
+		)
+		if err != nil {
+			return nil, err
+		}
+		duplicates = append(duplicates, &duplicate)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return duplicates, nil
+}
+
+func (m MovieModel) Merge(sourceID int64, target *Movie) ([]*MovieImage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	var locked int
+	err = tx.QueryRowContext(ctx, `
+        SELECT count(*)
+        FROM (SELECT id FROM movies WHERE id = ANY($1) ORDER BY id FOR UPDATE) AS locked
+    `, pq.Array([]int64{sourceID, target.ID})).Scan(&locked)
+	if err != nil {
+		return nil, err
+	}
+	if locked != 2 {
+		return nil, ErrRecordNotFound
+	}
+	repoint := []string{
+		`UPDATE movie_translations
+        SET movie_id = $2
+        WHERE movie_id = $1
+        AND locale NOT IN (SELECT locale FROM movie_translations WHERE movie_id = $2)`,
+		`UPDATE movie_images
+        SET movie_id = $2
+        WHERE movie_id = $1
+        AND kind NOT IN (SELECT kind FROM movie_images WHERE movie_id = $2)`,
+		`INSERT INTO movie_views (movie_id, bucket, views)
+        SELECT $2, bucket, views
+        FROM movie_views
+        WHERE movie_id = $1
+        ON CONFLICT (movie_id, bucket) DO UPDATE SET views = movie_views.views + excluded.views`,
+		`UPDATE watch_history
+        SET movie_id = $2
+        WHERE movie_id = $1`,
+		`UPDATE notifications
+        SET movie_id = $2
+        WHERE movie_id = $1
+        AND user_id NOT IN (SELECT user_id FROM notifications WHERE movie_id = $2)`,
+		`UPDATE collection_movies
+        SET movie_id = $2
+        WHERE movie_id = $1
+        AND NOT EXISTS (SELECT 1 FROM collection_movies WHERE movie_id = $2)`,
+	}
+	for _, statement := range repoint {
+		_, err = tx.ExecContext(ctx, statement, sourceID, target.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT kind, variants FROM movie_images WHERE movie_id = $1`, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	discarded := []*MovieImage{}
+	for rows.Next() {
+		image := MovieImage{MovieID: sourceID}
+		var variants []byte
+		err = rows.Scan(&image.Kind, &variants)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(variants, &image.Variants)
+		if err != nil {
+			return nil, err
+		}
+		discarded = append(discarded, &image)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM movies WHERE id = $1`, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	args, err := target.updateArgs()
+	if err != nil {
+		return nil, err
+	}
+	err = tx.QueryRowContext(ctx, updateMovieQuery, args...).Scan(&target.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrEditConflict
+		default:
+			return nil, duplicateExternalID(err)
+		}
+	}
+	err = writeOutbox(ctx, tx, EventMovieDeleted, map[string]int64{"id": sourceID, "merged_into": target.ID})
+	if err != nil {
+		return nil, err
+	}
+	err = writeOutbox(ctx, tx, EventMovieUpdated, target)
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return discarded, nil
+}
@@ -0,0 +1,136 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MovieEventChannel    = "movie_events"
+	MoviePresenceChannel = "movie_presence"
+)
+
+var ErrInvalidMovieEventCursor = errors.New("invalid movie event cursor")
+
+type MovieEventCursor struct {
+	XID int64
+	ID  int64
+}
+
+func ParseMovieEventCursor(s string) (MovieEventCursor, error) {
+	xid, id, ok := strings.Cut(s, "-")
+	if !ok {
+		return MovieEventCursor{}, ErrInvalidMovieEventCursor
+	}
+	var (
+		cursor MovieEventCursor
+		err    error
+	)
+	cursor.XID, err = strconv.ParseInt(xid, 10, 64)
+	if err != nil || cursor.XID < 0 {
+		return MovieEventCursor{}, ErrInvalidMovieEventCursor
+	}
+	cursor.ID, err = strconv.ParseInt(id, 10, 64)
+	if err != nil || cursor.ID < 0 {
+		return MovieEventCursor{}, ErrInvalidMovieEventCursor
+	}
+	return cursor, nil
+}
+
+func (c MovieEventCursor) String() string {
+	return fmt.Sprintf("%d-%d", c.XID, c.ID)
+}
+
+func (c MovieEventCursor) After(other MovieEventCursor) bool {
+	return c.XID > other.XID || (c.XID == other.XID && c.ID > other.ID)
+}
+
+type MovieEvent struct {
+	ID        int64            `json:"id"`
+	CreatedAt time.Time        `json:"created_at"`
+	MovieID   int64            `json:"movie_id"`
+	Operation string           `json:"operation"`
+	Version   int32            `json:"version"`
+	Cursor    MovieEventCursor `json:"-"`
+}
+
+type MovieEventModel struct {
+	DB *sql.DB
+}
+
+func (m MovieEventModel) Head(ctx context.Context) (MovieEventCursor, error) {
+	var cursor MovieEventCursor
+	err := m.DB.QueryRowContext(ctx, `SELECT pg_snapshot_xmin(pg_current_snapshot())::text::bigint`).Scan(&cursor.XID)
+	return cursor, err
+}
+
+func (m MovieEventModel) Since(ctx context.Context, after MovieEventCursor, limit int) ([]*MovieEvent, error) {
+	query := `
+        SELECT
+            id,
+            xid::text::bigint,
+            created_at,
+            movie_id,
+            operation,
+            version
+        FROM
+            movie_events
+        WHERE
+            (xid, id) > ($1::text::xid8, $2)
+            AND xid < pg_snapshot_xmin(pg_current_snapshot())
+        ORDER BY
+            xid ASC,
+            id ASC
+        LIMIT $3
+    `
+	rows, err := m.DB.QueryContext(ctx, query, after.XID, after.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := []*MovieEvent{}
+	for rows.Next() {
+		var event MovieEvent
+		err := rows.Scan(
+			&event.ID,
+			&event.Cursor.XID,
+			&event.CreatedAt,
+			&event.MovieID,
+			&event.Operation,
+			&event.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		event.Cursor.ID = event.ID
+		events = append(events, &event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (m MovieEventModel) DeleteOlderThan(ctx context.Context, age time.Duration) (int64, error) {
+	query := `
+        DELETE FROM
+            movie_events
+        WHERE
+            created_at < now() - make_interval(secs => $1)
+    `
+	result, err := m.DB.ExecContext(ctx, query, age.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (m MovieEventModel) Notify(ctx context.Context, channel string, payload []byte) error {
+	_, err := m.DB.ExecContext(ctx, `SELECT pg_notify($1, $2)`, channel, string(payload))
+	return err
+}
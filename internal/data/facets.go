@@ -0,0 +1,88 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type MovieFacets struct {
+	Genres   []FacetCount `json:"genres"`
+	Decades  []FacetCount `json:"decades"`
+	Runtimes []FacetCount `json:"runtimes"`
+}
+
+func (m MovieModel) Facets(criteria MovieCriteria) (*MovieFacets, error) {
+	genreCriteria := criteria
+	genreCriteria.Genres, genreCriteria.GenresAny, genreCriteria.GenresExclude = nil, nil, nil
+	genres, err := m.facetCounts("genre", "movies, unnest(movies.genres) AS genre", genreCriteria, "count(*) DESC, value ASC")
+	if err != nil {
+		return nil, err
+	}
+	decadeCriteria := criteria
+	decadeCriteria.YearMin, decadeCriteria.YearMax = 0, 0
+	decades, err := m.facetCounts("((year / 10) * 10)::text || 's'", "movies", decadeCriteria, "value ASC")
+	if err != nil {
+		return nil, err
+	}
+	runtimeCriteria := criteria
+	runtimeCriteria.RuntimeMin, runtimeCriteria.RuntimeMax = 0, 0
+	runtimeBucket := `
+            CASE
+                WHEN runtime < 90 THEN '0-89'
+                WHEN runtime < 120 THEN '90-119'
+                WHEN runtime < 150 THEN '120-149'
+                ELSE '150+'
+            END`
+	runtimes, err := m.facetCounts(runtimeBucket, "movies", runtimeCriteria, "min(runtime) ASC")
+	if err != nil {
+		return nil, err
+	}
+	return &MovieFacets{Genres: genres, Decades: decades, Runtimes: runtimes}, nil
+}
+
+func (m MovieModel) facetCounts(valueExpression, from string, criteria MovieCriteria, orderBy string) ([]FacetCount, error) {
+	where, args := criteria.whereClause()
+	query := fmt.Sprintf(`
+        SELECT
+            %s AS value,
+            count(*)
+        FROM
+            %s
+        WHERE%s
+        GROUP BY
+            value
+        ORDER BY
+            %s
+    `, valueExpression, from, where, orderBy)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	q, done, err := m.queryerFor(ctx, criteria)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := []FacetCount{}
+	for rows.Next() {
+		var count FacetCount
+		err := rows.Scan(&count.Value, &count.Count)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
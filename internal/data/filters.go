@@ -1,34 +1,83 @@
 package data
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"strings"
 
 	"greenlight.gustavosantos.net/internal/validator"
 )
 
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type Filters struct {
 	Page         int
 	PageSize     int
 	Sort         string
 	SortSafelist []string
+	CursorMode   bool
+	Cursor       string
+	CursorSecret []byte
+	SkipCount    bool
 }
 
-func (f Filters) sortColumn() string {
-	for _, safeValue := range f.SortSafelist {
-		if f.Sort == safeValue {
-			return strings.TrimPrefix(f.Sort, "-")
-		}
+type cursor struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+	Before bool     `json:"b,omitempty"`
+}
+
+type sortTerm struct {
+	column     string
+	descending bool
+}
+
+var sortExpressions = map[string]string{
+	"collection": "(SELECT position FROM collection_movies WHERE collection_movies.movie_id = movies.id)",
+}
+
+func (t sortTerm) expression() string {
+	if expression, ok := sortExpressions[t.column]; ok {
+		return expression
 	}
-	panic("unsafe sort parameter: " + f.Sort)
+	return t.column
 }
 
-func (f Filters) sortDirection() string {
-	if strings.HasPrefix(f.Sort, "-") {
+func (t sortTerm) direction(reverse bool) string {
+	if t.descending != reverse {
 		return "DESC"
 	}
 	return "ASC"
 }
 
+func (f Filters) sortKeys() []string {
+	return strings.Split(f.Sort, ",")
+}
+
+func (f Filters) sortTerms() []sortTerm {
+	var terms []sortTerm
+	hasID := false
+	for _, key := range f.sortKeys() {
+		if !validator.PermittedValue(key, f.SortSafelist...) {
+			panic("unsafe sort parameter: " + key)
+		}
+		term := sortTerm{column: strings.TrimPrefix(key, "-"), descending: strings.HasPrefix(key, "-")}
+		terms = append(terms, term)
+		if term.column == "id" {
+			hasID = true
+			break
+		}
+	}
+	if !hasID {
+		terms = append(terms, sortTerm{column: "id"})
+	}
+	return terms
+}
+
 func (f Filters) limit() int {
 	return f.PageSize
 }
@@ -37,20 +86,117 @@ func (f Filters) offset() int {
 	return (f.Page - 1) * f.PageSize
 }
 
+func (f Filters) orderBy(reverse bool) string {
+	var clauses []string
+	for _, term := range f.sortTerms() {
+		clauses = append(clauses, fmt.Sprintf("%s %s", term.expression(), term.direction(reverse)))
+	}
+	return strings.Join(clauses, ", ")
+}
+
+func (f Filters) keysetCondition(c *cursor, position int) (string, []any) {
+	terms := f.sortTerms()
+	var disjuncts []string
+	for i, term := range terms {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, fmt.Sprintf("%s = $%d", terms[j].column, position+j))
+		}
+		op := ">"
+		if term.descending != c.Before {
+			op = "<"
+		}
+		parts = append(parts, fmt.Sprintf("%s %s $%d", term.column, op, position+i))
+		disjuncts = append(disjuncts, "("+strings.Join(parts, " AND ")+")")
+	}
+	args := make([]any, len(c.Values))
+	for i, value := range c.Values {
+		args[i] = value
+	}
+	return "(" + strings.Join(disjuncts, " OR ") + ")", args
+}
+
+func (f Filters) cursorFor(movie *Movie, before bool) string {
+	var values []string
+	for _, term := range f.sortTerms() {
+		values = append(values, movie.sortValue(term.column))
+	}
+	return f.encodeCursor(cursor{Sort: f.Sort, Values: values, Before: before})
+}
+
+func (f Filters) encodeCursor(c cursor) string {
+	payload, _ := json.Marshal(c)
+	mac := hmac.New(sha256.New, f.CursorSecret)
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (f Filters) decodeCursor() (*cursor, error) {
+	if f.Cursor == "" {
+		return nil, nil
+	}
+	payloadPart, signaturePart, found := strings.Cut(f.Cursor, ".")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(payloadPart)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(signaturePart)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	mac := hmac.New(sha256.New, f.CursorSecret)
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != f.Sort || len(c.Values) != len(f.sortTerms()) {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
 func ValidateFilters(v *validator.Validator, f Filters) {
 	v.Check(f.Page > 0, "page", "must be greater than zero")
 	v.Check(f.Page <= 10_000_000, "page", "must be a maximum of 10 million")
 	v.Check(f.PageSize > 0, "page_size", "must be greater than zero")
 	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
-	v.Check(validator.PermittedValue[string](f.Sort, f.SortSafelist...), "sort", "invalid sort value")
+	sortValid := true
+	seen := make(map[string]bool)
+	for _, key := range f.sortKeys() {
+		column := strings.TrimPrefix(key, "-")
+		switch {
+		case !validator.PermittedValue(key, f.SortSafelist...):
+			v.AddError("sort", "invalid sort value")
+			sortValid = false
+		case seen[column]:
+			v.AddError("sort", "must not contain duplicate or conflicting sort keys")
+			sortValid = false
+		}
+		seen[column] = true
+	}
+	if f.CursorMode && sortValid {
+		v.Check(f.Page == 1, "page", "must not be used with cursor pagination")
+		_, err := f.decodeCursor()
+		v.Check(err == nil, "cursor", "invalid cursor")
+	}
 }
 
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	PrevCursor   string `json:"prev_cursor,omitempty"`
+	HasNextPage  bool   `json:"-"`
 }
 
 func calculateMetadata(totalRecords, page, pageSize int) Metadata {
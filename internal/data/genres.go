@@ -0,0 +1,429 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/lib/pq"
+	"greenlight.gustavosantos.net/internal/validator"
+)
+
+var (
+	ErrDuplicateGenre = errors.New("duplicate genre")
+	SlugRX            = regexp.MustCompile("^[a-z0-9]+(?:-[a-z0-9]+)*$")
+)
+
+type Genre struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"-"`
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	Aliases   []string  `json:"aliases"`
+	Version   int32     `json:"version"`
+}
+
+type GenreSet map[string]string
+
+func (s GenreSet) Resolve(name string) (string, bool) {
+	if slug, ok := s[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return slug, true
+	}
+	slug, ok := s[Slugify(name)]
+	return slug, ok
+}
+
+func Slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+func ValidateGenre(v *validator.Validator, genre *Genre) {
+	v.Check(genre.Slug != "", "slug", "must be provided")
+	v.Check(validator.Matches(genre.Slug, SlugRX), "slug", "must only contain lowercase letters, digits and single dashes")
+	v.Check(len(genre.Slug) <= 100, "slug", "must not be more than 100 bytes long")
+	v.Check(genre.Name != "", "name", "must be provided")
+	v.Check(len(genre.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(len(genre.Aliases) <= 20, "aliases", "must not contain more than 20 aliases")
+	v.Check(validator.Unique(genre.Aliases), "aliases", "must not contain duplicate values")
+	for _, alias := range genre.Aliases {
+		v.Check(alias != "", "aliases", "must not contain empty values")
+		v.Check(alias != genre.Slug, "aliases", "must not contain the genre slug")
+	}
+}
+
+type GenreModel struct {
+	DB *sql.DB
+}
+
+func (m GenreModel) GetAll() ([]*Genre, error) {
+	query := `
+        SELECT
+            id,
+            created_at,
+            slug,
+            name,
+            aliases,
+            version
+        FROM
+            genres
+        ORDER BY
+            name ASC,
+            id ASC
+    `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	genres := []*Genre{}
+	for rows.Next() {
+		var genre Genre
+		err := rows.Scan(
+			&genre.ID,
+			&genre.CreatedAt,
+			&genre.Slug,
+			&genre.Name,
+			pq.Array(&genre.Aliases),
+			&genre.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		genres = append(genres, &genre)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return genres, nil
+}
+
+func (m GenreModel) Lookup() (GenreSet, error) {
+	genres, err := m.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	set := make(GenreSet)
+	for _, genre := range genres {
+		set[genre.Slug] = genre.Slug
+		set[strings.ToLower(genre.Name)] = genre.Slug
+		for _, alias := range genre.Aliases {
+			set[alias] = genre.Slug
+		}
+	}
+	return set, nil
+}
+
+func (m GenreModel) Get(id int64) (*Genre, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+        SELECT
+            id,
+            created_at,
+            slug,
+            name,
+            aliases,
+            version
+        FROM
+            genres
+        WHERE
+            id = $1
+    `
+	var genre Genre
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&genre.ID,
+		&genre.CreatedAt,
+		&genre.Slug,
+		&genre.Name,
+		pq.Array(&genre.Aliases),
+		&genre.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &genre, nil
+}
+
+func (m GenreModel) nameTaken(id int64, genre *Genre) (bool, error) {
+	query := `
+        SELECT EXISTS (
+            SELECT 1
+            FROM genres
+            WHERE id <> $1
+            AND (slug = ANY($2) OR aliases && $2)
+        )
+    `
+	names := append([]string{genre.Slug}, genre.Aliases...)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var taken bool
+	err := m.DB.QueryRowContext(ctx, query, id, pq.Array(names)).Scan(&taken)
+	return taken, err
+}
+
+func (m GenreModel) Insert(genre *Genre) error {
+	taken, err := m.nameTaken(0, genre)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrDuplicateGenre
+	}
+	query := `
+        INSERT INTO genres (
+            slug,
+            name,
+            aliases
+        )
+        VALUES (
+            $1,
+            $2,
+            $3
+        )
+        RETURNING
+            id,
+            created_at,
+            version
+    `
+	args := []any{genre.Slug, genre.Name, pq.Array(genre.Aliases)}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&genre.ID, &genre.CreatedAt, &genre.Version)
+	if err != nil {
+		switch {
+		case violatesConstraint(err, uniqueViolation, "genres_slug_key"):
+			return ErrDuplicateGenre
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m GenreModel) Update(genre *Genre) error {
+	taken, err := m.nameTaken(genre.ID, genre)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrDuplicateGenre
+	}
+	query := `
+        UPDATE
+            genres
+        SET
+            name = $1,
+            aliases = $2,
+            version = version + 1
+        WHERE
+            id = $3
+            AND version = $4
+        RETURNING
+            version
+    `
+	args := []any{genre.Name, pq.Array(genre.Aliases), genre.ID, genre.Version}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&genre.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m GenreModel) InUse(slug string) (bool, error) {
+	query := `
+        SELECT EXISTS (
+            SELECT 1
+            FROM movies
+            WHERE genres @> ARRAY[$1::text]
+        )
+    `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var inUse bool
+	err := m.DB.QueryRowContext(ctx, query, slug).Scan(&inUse)
+	return inUse, err
+}
+
+func (m GenreModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `
+        DELETE FROM
+            genres
+        WHERE
+            id = $1
+    `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m GenreModel) Merge(sourceID, targetID int64) (*Genre, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+	var source Genre
+	err = tx.QueryRowContext(ctx, `
+        SELECT slug, name, aliases
+        FROM genres
+        WHERE id = $1
+        FOR UPDATE
+    `, sourceID).Scan(&source.Slug, &source.Name, pq.Array(&source.Aliases))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, 0, ErrRecordNotFound
+		default:
+			return nil, 0, err
+		}
+	}
+	target := Genre{ID: targetID}
+	err = tx.QueryRowContext(ctx, `
+        UPDATE
+            genres
+        SET
+            aliases = ARRAY(
+                SELECT DISTINCT alias
+                FROM unnest(aliases || $2::text[]) AS alias
+                WHERE alias <> slug
+                ORDER BY alias
+            ),
+            version = version + 1
+        WHERE
+            id = $1
+        RETURNING
+            created_at,
+            slug,
+            name,
+            aliases,
+            version
+    `, targetID, pq.Array(append([]string{source.Slug, strings.ToLower(source.Name)}, source.Aliases...))).Scan(
+		&target.CreatedAt,
+		&target.Slug,
+		&target.Name,
+		pq.Array(&target.Aliases),
+		&target.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, 0, ErrRecordNotFound
+		default:
+			return nil, 0, err
+		}
+	}
+	rows, err := tx.QueryContext(ctx, `
+        UPDATE
+            movies
+        SET
+            genres = ARRAY(
+                SELECT genre
+                FROM (
+                    SELECT DISTINCT ON (genre) genre, position
+                    FROM unnest(array_replace(genres, $1, $2)) WITH ORDINALITY AS replaced(genre, position)
+                    ORDER BY genre, position
+                ) AS deduplicated
+                ORDER BY position
+            ),
+            updated_at = NOW(),
+            version = version + 1
+        WHERE
+            genres @> ARRAY[$1::text]
+        RETURNING
+            id,
+            created_at,
+            title,
+            year,
+            runtime,
+            genres,
+            version
+    `, source.Slug, target.Slug)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var updated []any
+	for rows.Next() {
+		var movie Movie
+		err := rows.Scan(
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Version,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		updated = append(updated, &movie)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(updated) > 0 {
+		err = writeOutbox(ctx, tx, EventMovieUpdated, updated...)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM genres WHERE id = $1`, sourceID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, 0, err
+	}
+	return &target, int64(len(updated)), nil
+}
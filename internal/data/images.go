@@ -0,0 +1,180 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	ImageKindPoster   = "poster"
+	ImageKindBackdrop = "backdrop"
+)
+
+var ImageSizes = map[string][]int{
+	ImageKindPoster:   {92, 185, 342, 500},
+	ImageKindBackdrop: {300, 780, 1280},
+}
+
+type MovieImage struct {
+	ID          int64             `json:"-"`
+	CreatedAt   time.Time         `json:"-"`
+	MovieID     int64             `json:"-"`
+	Kind        string            `json:"kind"`
+	ContentType string            `json:"content_type"`
+	Width       int               `json:"width"`
+	Height      int               `json:"height"`
+	Variants    map[string]string `json:"-"`
+}
+
+type MovieImageModel struct {
+	DB *sql.DB
+}
+
+func (m MovieImageModel) Get(movieID int64, kind string) (*MovieImage, error) {
+	query := `
+        SELECT
+            id,
+            created_at,
+            movie_id,
+            kind,
+            content_type,
+            width,
+            height,
+            variants
+        FROM
+            movie_images
+        WHERE
+            movie_id = $1
+            AND kind = $2
+    `
+	var (
+		image    MovieImage
+		variants []byte
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, movieID, kind).Scan(
+		&image.ID,
+		&image.CreatedAt,
+		&image.MovieID,
+		&image.Kind,
+		&image.ContentType,
+		&image.Width,
+		&image.Height,
+		&variants,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	err = json.Unmarshal(variants, &image.Variants)
+	if err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+func (m MovieImageModel) GetAllForMovies(movieIDs []int64) ([]*MovieImage, error) {
+	query := `
+        SELECT
+            id,
+            created_at,
+            movie_id,
+            kind,
+            content_type,
+            width,
+            height,
+            variants
+        FROM
+            movie_images
+        WHERE
+            movie_id = ANY($1)
+        ORDER BY
+            movie_id,
+            kind
+    `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	images := []*MovieImage{}
+	for rows.Next() {
+		var (
+			image    MovieImage
+			variants []byte
+		)
+		err := rows.Scan(
+			&image.ID,
+			&image.CreatedAt,
+			&image.MovieID,
+			&image.Kind,
+			&image.ContentType,
+			&image.Width,
+			&image.Height,
+			&variants,
+		)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(variants, &image.Variants)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, &image)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+func (m MovieImageModel) Upsert(image *MovieImage) error {
+	variants, err := json.Marshal(image.Variants)
+	if err != nil {
+		return err
+	}
+	query := `
+        INSERT INTO movie_images (
+            movie_id,
+            kind,
+            content_type,
+            width,
+            height,
+            variants
+        )
+        VALUES (
+            $1,
+            $2,
+            $3,
+            $4,
+            $5,
+            $6
+        )
+        ON CONFLICT (movie_id, kind) DO UPDATE
+        SET
+            created_at = NOW(),
+            content_type = EXCLUDED.content_type,
+            width = EXCLUDED.width,
+            height = EXCLUDED.height,
+            variants = EXCLUDED.variants
+        RETURNING
+            id,
+            created_at
+    `
+	args := []any{image.MovieID, image.Kind, image.ContentType, image.Width, image.Height, variants}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&image.ID, &image.CreatedAt)
+}
@@ -3,6 +3,13 @@ package data
 import (
 	"database/sql"
 	"errors"
+
+	"github.com/lib/pq"
+)
+
+const (
+	foreignKeyViolation = pq.ErrorCode("23503")
+	uniqueViolation     = pq.ErrorCode("23505")
 )
 
 var (
@@ -10,18 +17,43 @@ var (
 	ErrEditConflict   = errors.New("edit conflict")
 )
 
+func violatesConstraint(err error, code pq.ErrorCode, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == code && pqErr.Constraint == constraint
+}
+
 type Models struct {
-	Movies      MovieModel
-	Permissions PermissionModel
-	Users       UserModel
-	Tokens      TokenModel
+	Collections       CollectionModel
+	Genres            GenreModel
+	Movies            MovieModel
+	MovieEvents       MovieEventModel
+	MovieImages       MovieImageModel
+	MovieTranslations MovieTranslationModel
+	Notifications     NotificationModel
+	Outbox            OutboxModel
+	Permissions       PermissionModel
+	Popularity        PopularityModel
+	Recommendations   RecommendationModel
+	Users             UserModel
+	Tokens            TokenModel
+	Webhooks          WebhookModel
 }
 
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Movies:      MovieModel{DB: db},
-		Permissions: PermissionModel{DB: db},
-		Users:       UserModel{DB: db},
-		Tokens:      TokenModel{DB: db},
+		Collections:       CollectionModel{DB: db},
+		Genres:            GenreModel{DB: db},
+		Movies:            MovieModel{DB: db},
+		MovieEvents:       MovieEventModel{DB: db},
+		MovieImages:       MovieImageModel{DB: db},
+		MovieTranslations: MovieTranslationModel{DB: db},
+		Notifications:     NotificationModel{DB: db},
+		Outbox:            OutboxModel{DB: db},
+		Permissions:       PermissionModel{DB: db},
+		Popularity:        PopularityModel{DB: db},
+		Recommendations:   RecommendationModel{DB: db},
+		Users:             UserModel{DB: db},
+		Tokens:            TokenModel{DB: db},
+		Webhooks:          WebhookModel{DB: db},
 	}
 }
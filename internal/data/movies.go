@@ -3,25 +3,82 @@ package data
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/lib/pq"
 	"greenlight.gustavosantos.net/internal/validator"
 )
 
+const (
+	ExternalSourceIMDb = "imdb"
+	ExternalSourceTMDB = "tmdb"
+)
+
+var (
+	ErrDuplicateIMDbID = errors.New("duplicate imdb id")
+	ErrDuplicateTMDBID = errors.New("duplicate tmdb id")
+	IMDbIDRX           = regexp.MustCompile(`^tt[0-9]{7,10}$`)
+	LanguageRX         = regexp.MustCompile("^[a-z]{2}$")
+	CountryRX          = regexp.MustCompile("^[A-Z]{2}$")
+)
+
 type Movie struct {
-	ID        int64     `json:"id"`
-	Title     string    `json:"title"`
-	Year      int32     `json:"year,omitempty"`
-	Runtime   Runtime   `json:"runtime,omitempty"`
-	Genres    []string  `json:"genres,omitempty"`
-	Version   int32     `json:"version"`
-	CreatedAt time.Time `json:"-"`
+	ID               int64                        `json:"id"`
+	Title            string                       `json:"title"`
+	OriginalTitle    string                       `json:"original_title,omitempty"`
+	Locale           string                       `json:"locale,omitempty"`
+	Overview         string                       `json:"overview,omitempty"`
+	OriginalLanguage string                       `json:"original_language,omitempty"`
+	Year             int32                        `json:"year,omitempty"`
+	Runtime          Runtime                      `json:"runtime,omitempty"`
+	Genres           []string                     `json:"genres,omitempty"`
+	Releases         map[string]MovieRelease      `json:"releases,omitempty"`
+	ExternalIDs      *ExternalIDs                 `json:"external_ids,omitempty"`
+	Collection       *CollectionSummary           `json:"collection,omitempty"`
+	Version          int32                        `json:"version"`
+	Images           map[string]map[string]string `json:"images,omitempty"`
+	CreatedAt        time.Time                    `json:"-"`
 }
 
-func ValidateMovie(v *validator.Validator, movie *Movie) {
+type MovieRelease struct {
+	Date          string `json:"date"`
+	Certification string `json:"certification,omitempty"`
+}
+
+type ExternalIDs struct {
+	IMDb string `json:"imdb,omitempty"`
+	TMDB int64  `json:"tmdb,omitempty"`
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullInt64(n int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: n, Valid: n != 0}
+}
+
+func (movie *Movie) sortValue(column string) string {
+	switch column {
+	case "title":
+		return movie.Title
+	case "year":
+		return strconv.Itoa(int(movie.Year))
+	case "runtime":
+		return strconv.Itoa(int(movie.Runtime))
+	default:
+		return strconv.FormatInt(movie.ID, 10)
+	}
+}
+
+func ValidateMovie(v *validator.Validator, movie *Movie, genres GenreSet) {
 	v.Check(movie.Title != "", "title", "must be provided")
 	v.Check(len(movie.Title) <= 500, "title", "must not be more than 500 bytes long")
 	v.Check(movie.Year != 0, "year", "must be provided")
@@ -32,17 +89,179 @@ func ValidateMovie(v *validator.Validator, movie *Movie) {
 	v.Check(movie.Genres != nil, "genres", "must be provided")
 	v.Check(len(movie.Genres) >= 1, "genres", "must contain at least 1 genre")
 	v.Check(len(movie.Genres) <= 5, "genres", "must not contain more than 5 genres")
+	if genres != nil {
+		for i, name := range movie.Genres {
+			slug, ok := genres.Resolve(name)
+			v.Check(ok, "genres", fmt.Sprintf("unknown genre %q", name))
+			if ok {
+				movie.Genres[i] = slug
+			}
+		}
+	}
 	v.Check(validator.Unique(movie.Genres), "genres", "must not contain duplicate values")
+	v.Check(len(movie.Overview) <= 5000, "overview", "must not be more than 5000 bytes long")
+	v.Check(movie.OriginalLanguage == "" || validator.Matches(movie.OriginalLanguage, LanguageRX), "original_language", "must be a two-letter ISO 639-1 code")
+	v.Check(len(movie.Releases) <= 250, "releases", "must not contain more than 250 countries")
+	for country, release := range movie.Releases {
+		key := "releases." + country
+		v.Check(validator.Matches(country, CountryRX), key, "country must be a two-letter ISO 3166-1 code")
+		_, err := time.Parse(time.DateOnly, release.Date)
+		v.Check(err == nil, key, "date must be in YYYY-MM-DD format")
+		v.Check(len(release.Certification) <= 20, key, "certification must not be more than 20 bytes long")
+	}
+	if movie.ExternalIDs != nil {
+		v.Check(movie.ExternalIDs.IMDb == "" || validator.Matches(movie.ExternalIDs.IMDb, IMDbIDRX), "external_ids.imdb", "must be a valid IMDb ID such as tt0111161")
+		v.Check(movie.ExternalIDs.TMDB >= 0, "external_ids.tmdb", "must be a positive integer")
+	}
+}
+
+type MovieCriteria struct {
+	Title          string
+	Fuzzy          bool
+	FuzzyThreshold float64
+	Genres         []string
+	GenresAny      []string
+	GenresExclude  []string
+	YearMin        int
+	YearMax        int
+	RuntimeMin     int
+	RuntimeMax     int
+	CreatedAfter   time.Time
+	CreatedBefore  time.Time
+	IDs            []int64
+	CollectionID   int64
+}
+
+func ValidateMovieCriteria(v *validator.Validator, c MovieCriteria) {
+	currentYear := time.Now().Year()
+	v.Check(c.YearMin == 0 || (c.YearMin >= 1888 && c.YearMin <= currentYear), "year_min", fmt.Sprintf("must be between 1888 and %d", currentYear))
+	v.Check(c.YearMax == 0 || (c.YearMax >= 1888 && c.YearMax <= currentYear), "year_max", fmt.Sprintf("must be between 1888 and %d", currentYear))
+	v.Check(c.YearMin == 0 || c.YearMax == 0 || c.YearMin <= c.YearMax, "year_max", "must not be less than year_min")
+	v.Check(c.RuntimeMin >= 0, "runtime_min", "must not be negative")
+	v.Check(c.RuntimeMax >= 0, "runtime_max", "must not be negative")
+	v.Check(c.RuntimeMin == 0 || c.RuntimeMax == 0 || c.RuntimeMin <= c.RuntimeMax, "runtime_max", "must not be less than runtime_min")
+	v.Check(c.CreatedAfter.IsZero() || c.CreatedBefore.IsZero() || c.CreatedAfter.Before(c.CreatedBefore), "created_before", "must be later than created_after")
+	v.Check(len(c.GenresAny) <= 20, "genres_any", "must not contain more than 20 genres")
+	v.Check(len(c.GenresExclude) <= 20, "genres_exclude", "must not contain more than 20 genres")
+	v.Check(!c.Fuzzy || (c.FuzzyThreshold > 0 && c.FuzzyThreshold <= 1), "fuzzy", "similarity threshold must be between 0 and 1")
+	v.Check(len(c.IDs) <= 100, "ids", "must not contain more than 100 values")
+	for _, id := range c.IDs {
+		v.Check(id > 0, "ids", "must only contain positive integers")
+	}
+	v.Check(c.CollectionID >= 0, "collection_id", "must be a positive integer")
+}
+
+func (c MovieCriteria) whereClause() (string, []any) {
+	var (
+		conditions []string
+		args       []any
+	)
+	add := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if c.Title != "" && c.Fuzzy {
+		add(`movies.id IN (
+                SELECT id
+                FROM movies
+                WHERE to_tsvector('simple', title) @@ plainto_tsquery('simple', $%[1]d)
+                OR $%[1]d <%% title
+                UNION
+                SELECT movie_id
+                FROM movie_translations
+                WHERE to_tsvector('simple', title) @@ plainto_tsquery('simple', $%[1]d)
+                OR $%[1]d <%% title
+            )`, c.Title)
+	} else if c.Title != "" {
+		add(`(
+                to_tsvector('simple', movies.title) @@ plainto_tsquery('simple', $%[1]d)
+                OR EXISTS (
+                    SELECT 1
+                    FROM movie_translations
+                    WHERE movie_translations.movie_id = movies.id
+                    AND to_tsvector('simple', movie_translations.title) @@ plainto_tsquery('simple', $%[1]d)
+                )
+            )`, c.Title)
+	}
+	if len(c.Genres) > 0 {
+		add("genres @> $%d", pq.Array(c.Genres))
+	}
+	if len(c.GenresAny) > 0 {
+		add("genres && $%d", pq.Array(c.GenresAny))
+	}
+	if len(c.GenresExclude) > 0 {
+		add("NOT genres && $%d", pq.Array(c.GenresExclude))
+	}
+	if c.YearMin > 0 {
+		add("year >= $%d", c.YearMin)
+	}
+	if c.YearMax > 0 {
+		add("year <= $%d", c.YearMax)
+	}
+	if c.RuntimeMin > 0 {
+		add("runtime >= $%d", c.RuntimeMin)
+	}
+	if c.RuntimeMax > 0 {
+		add("runtime <= $%d", c.RuntimeMax)
+	}
+	if !c.CreatedAfter.IsZero() {
+		add("created_at > $%d", c.CreatedAfter)
+	}
+	if !c.CreatedBefore.IsZero() {
+		add("created_at < $%d", c.CreatedBefore)
+	}
+	if len(c.IDs) > 0 {
+		add("id = ANY($%d)", pq.Array(c.IDs))
+	}
+	if c.CollectionID > 0 {
+		add("movies.id IN (SELECT movie_id FROM collection_movies WHERE collection_id = $%d)", c.CollectionID)
+	}
+	if len(conditions) == 0 {
+		return "\n            TRUE", args
+	}
+	return "\n            " + strings.Join(conditions, "\n            AND "), args
 }
 
 type MovieModel struct {
 	DB *sql.DB
 }
 
-func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error) {
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (m MovieModel) queryerFor(ctx context.Context, criteria MovieCriteria) (queryer, func(), error) {
+	if criteria.Title == "" || !criteria.Fuzzy {
+		return m.DB, func() {}, nil
+	}
+	tx, err := m.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	query := `SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)`
+	_, err = tx.ExecContext(ctx, query, strconv.FormatFloat(criteria.FuzzyThreshold, 'f', -1, 64))
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+	return tx, func() { tx.Rollback() }, nil
+}
+
+func (m MovieModel) GetAll(criteria MovieCriteria, filters Filters) ([]*Movie, Metadata, error) {
+	if filters.CursorMode {
+		return m.getAllByCursor(criteria, filters)
+	}
+	countColumn := "count(*) OVER()"
+	limit := filters.limit()
+	if filters.SkipCount {
+		countColumn = "0"
+		limit++
+	}
+	where, args := criteria.whereClause()
 	query := fmt.Sprintf(`
         SELECT
-            count(*) OVER(),
+            %s,
             id,
             created_at,
             title,
@@ -52,20 +271,21 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
             version
         FROM
             movies
-        WHERE
-            (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
-            AND (genres @> $2 OR $2 = '{}')
+        WHERE%s
         ORDER BY
             %s
-            %s,
-            id ASC
-        LIMIT $3
-        OFFSET $4
-    `, filters.sortColumn(), filters.sortDirection())
+        LIMIT $%d
+        OFFSET $%d
+    `, countColumn, where, filters.orderBy(false), len(args)+1, len(args)+2)
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	args := []any{title, pq.Array(genres), filters.limit(), filters.offset()}
-	rows, err := m.DB.QueryContext(ctx, query, args...)
+	args = append(args, limit, filters.offset())
+	q, done, err := m.queryerFor(ctx, criteria)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer done()
+	rows, err := q.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, Metadata{}, err
 	}
@@ -92,44 +312,240 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 	if rowsErr := rows.Err(); rowsErr != nil {
 		return nil, Metadata{}, rowsErr
 	}
+	if filters.SkipCount {
+		metadata := Metadata{
+			CurrentPage: filters.Page,
+			PageSize:    filters.PageSize,
+			FirstPage:   1,
+			HasNextPage: len(movies) > filters.limit(),
+		}
+		if metadata.HasNextPage {
+			movies = movies[:filters.limit()]
+		}
+		return movies, metadata, nil
+	}
 	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	metadata.HasNextPage = filters.Page < metadata.LastPage
 	return movies, metadata, nil
 }
 
+func (m MovieModel) getAllByCursor(criteria MovieCriteria, filters Filters) ([]*Movie, Metadata, error) {
+	c, err := filters.decodeCursor()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	where, args := criteria.whereClause()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	q, done, err := m.queryerFor(ctx, criteria)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer done()
+	metadata := Metadata{PageSize: filters.PageSize}
+	if !filters.SkipCount {
+		countErr := m.count(ctx, q, where, args, &metadata.TotalRecords)
+		if countErr != nil {
+			return nil, Metadata{}, countErr
+		}
+	}
+	backward := c != nil && c.Before
+	if c != nil {
+		condition, keysetArgs := filters.keysetCondition(c, len(args)+1)
+		where += "\n            AND " + condition
+		args = append(args, keysetArgs...)
+	}
+	query := fmt.Sprintf(`
+        SELECT
+            id,
+            created_at,
+            title,
+            year,
+            runtime,
+            genres,
+            version
+        FROM
+            movies
+        WHERE%s
+        ORDER BY
+            %s
+        LIMIT $%d
+    `, where, filters.orderBy(backward), len(args)+1)
+	args = append(args, filters.limit()+1)
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	movies := []*Movie{}
+	for rows.Next() {
+		var movie Movie
+		err := rows.Scan(
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		movies = append(movies, &movie)
+	}
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return nil, Metadata{}, rowsErr
+	}
+	hasMore := len(movies) > filters.limit()
+	if hasMore {
+		movies = movies[:filters.limit()]
+	}
+	if backward {
+		slices.Reverse(movies)
+	}
+	if len(movies) == 0 {
+		return movies, metadata, nil
+	}
+	if (backward && hasMore) || (!backward && c != nil) {
+		metadata.PrevCursor = filters.cursorFor(movies[0], true)
+	}
+	if (!backward && hasMore) || backward {
+		metadata.NextCursor = filters.cursorFor(movies[len(movies)-1], false)
+		metadata.HasNextPage = true
+	}
+	return movies, metadata, nil
+}
+
+func (m MovieModel) count(ctx context.Context, q queryer, where string, args []any, total *int) error {
+	query := fmt.Sprintf(`
+        SELECT
+            count(*)
+        FROM
+            movies
+        WHERE%s
+    `, where)
+	return q.QueryRowContext(ctx, query, args...).Scan(total)
+}
+
 func (m MovieModel) Insert(movie *Movie) error {
 	query := `
         INSERT INTO movies (
             title,
             year,
             runtime,
-            genres
+            genres,
+            overview,
+            original_language,
+            releases,
+            imdb_id,
+            tmdb_id
         )
         VALUES (
             $1,
             $2,
             $3,
-            $4
+            $4,
+            $5,
+            $6,
+            $7,
+            $8,
+            $9
         )
         RETURNING
             id,
             created_at,
             version
     `
-	args := []any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)}
+	args, err := movie.detailArgs()
+	if err != nil {
+		return err
+	}
+	args = append([]any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)}, args...)
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = tx.QueryRowContext(ctx, query, args...).Scan(
 		&movie.ID,
 		&movie.CreatedAt,
 		&movie.Version,
 	)
+	if err != nil {
+		return duplicateExternalID(err)
+	}
+	err = writeOutbox(ctx, tx, EventMovieCreated, movie)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (movie *Movie) detailArgs() ([]any, error) {
+	releases := movie.Releases
+	if releases == nil {
+		releases = map[string]MovieRelease{}
+	}
+	releasesJSON, err := json.Marshal(releases)
+	if err != nil {
+		return nil, err
+	}
+	var externalIDs ExternalIDs
+	if movie.ExternalIDs != nil {
+		externalIDs = *movie.ExternalIDs
+	}
+	return []any{
+		movie.Overview,
+		movie.OriginalLanguage,
+		releasesJSON,
+		nullString(externalIDs.IMDb),
+		nullInt64(externalIDs.TMDB),
+	}, nil
+}
+
+func (movie *Movie) updateArgs() ([]any, error) {
+	args, err := movie.detailArgs()
+	if err != nil {
+		return nil, err
+	}
+	args = append([]any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)}, args...)
+	return append(args, movie.ID, movie.Version), nil
+}
+
+func duplicateExternalID(err error) error {
+	switch {
+	case violatesConstraint(err, uniqueViolation, "movies_imdb_id_idx"):
+		return ErrDuplicateIMDbID
+	case violatesConstraint(err, uniqueViolation, "movies_tmdb_id_idx"):
+		return ErrDuplicateTMDBID
+	default:
+		return err
+	}
 }
 
 func (m MovieModel) Get(id int64) (*Movie, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
-	query := `
+	return m.getWhere("id = $1", id)
+}
+
+func (m MovieModel) GetByIMDbID(imdbID string) (*Movie, error) {
+	return m.getWhere("imdb_id = $1", imdbID)
+}
+
+func (m MovieModel) GetByTMDBID(tmdbID int64) (*Movie, error) {
+	if tmdbID < 1 {
+		return nil, ErrRecordNotFound
+	}
+	return m.getWhere("tmdb_id = $1", tmdbID)
+}
+
+func (m MovieModel) getWhere(condition string, arg any) (*Movie, error) {
+	query := fmt.Sprintf(`
         SELECT
             id,
             created_at,
@@ -137,23 +553,37 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
             year,
             runtime,
             genres,
+            overview,
+            original_language,
+            releases,
+            imdb_id,
+            tmdb_id,
             version
         FROM
             movies
         WHERE
-            id = $1
-    `
-	var movie Movie
+            %s
+    `, condition)
+	var (
+		movie    Movie
+		releases []byte
+		imdbID   sql.NullString
+		tmdbID   sql.NullInt64
+	)
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(
-		&[]byte{},
+	err := m.DB.QueryRowContext(ctx, query, arg).Scan(
 		&movie.ID,
 		&movie.CreatedAt,
 		&movie.Title,
 		&movie.Year,
 		&movie.Runtime,
 		pq.Array(&movie.Genres),
+		&movie.Overview,
+		&movie.OriginalLanguage,
+		&releases,
+		&imdbID,
+		&tmdbID,
 		&movie.Version,
 	)
 	if err != nil {
@@ -164,11 +594,15 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 			return nil, err
 		}
 	}
+	err = json.Unmarshal(releases, &movie.Releases)
+	if err != nil {
+		return nil, err
+	}
+	movie.ExternalIDs = &ExternalIDs{IMDb: imdbID.String, TMDB: tmdbID.Int64}
 	return &movie, nil
 }
 
-func (m MovieModel) Update(movie *Movie) error {
-	query := `
+const updateMovieQuery = `
         UPDATE
             movies
         SET
@@ -176,33 +610,45 @@ func (m MovieModel) Update(movie *Movie) error {
             year = $2,
             runtime = $3,
             genres = $4,
+            overview = $5,
+            original_language = $6,
+            releases = $7,
+            imdb_id = $8,
+            tmdb_id = $9,
+            updated_at = NOW(),
             version = version + 1
         WHERE
-            id = $5
-            AND version = $6
+            id = $10
+            AND version = $11
         RETURNING version
     `
-	args := []any{
-		movie.Title,
-		movie.Year,
-		movie.Runtime,
-		pq.Array(movie.Genres),
-		movie.ID,
-		movie.Version,
+
+func (m MovieModel) Update(movie *Movie) error {
+	args, err := movie.updateArgs()
+	if err != nil {
+		return err
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = tx.QueryRowContext(ctx, updateMovieQuery, args...).Scan(&movie.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			return ErrEditConflict
 		default:
-			return err
-
+			return duplicateExternalID(err)
 		}
 	}
-	return nil
+	err = writeOutbox(ctx, tx, EventMovieUpdated, movie)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (m MovieModel) Delete(id int64) error {
@@ -217,7 +663,12 @@ func (m MovieModel) Delete(id int64) error {
     `
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	result, err := m.DB.ExecContext(ctx, query, id)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	result, err := tx.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -228,5 +679,140 @@ func (m MovieModel) Delete(id int64) error {
 	if rowsAffected == 0 {
 		return ErrRecordNotFound
 	}
-	return nil
+	err = writeOutbox(ctx, tx, EventMovieDeleted, map[string]int64{"id": id})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type MovieBatch struct {
+	tx *sql.Tx
+}
+
+func (m MovieModel) BeginBatch() (*MovieBatch, error) {
+	tx, err := m.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &MovieBatch{tx: tx}, nil
+}
+
+func (b *MovieBatch) Insert(movies []*Movie) error {
+	if len(movies) == 0 {
+		return nil
+	}
+	values := make([]string, 0, len(movies))
+	args := make([]any, 0, len(movies)*4)
+	for i, movie := range movies {
+		n := i * 4
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4))
+		args = append(args, movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres))
+	}
+	query := fmt.Sprintf(`
+        INSERT INTO movies (
+            title,
+            year,
+            runtime,
+            genres
+        )
+        VALUES
+            %s
+        RETURNING
+            id,
+            created_at,
+            version
+    `, strings.Join(values, ",\n            "))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	rows, err := b.tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	i := 0
+	for rows.Next() {
+		if i >= len(movies) {
+			return errors.New("batch insert returned more rows than expected")
+		}
+		scanErr := rows.Scan(&movies[i].ID, &movies[i].CreatedAt, &movies[i].Version)
+		if scanErr != nil {
+			return scanErr
+		}
+		i++
+	}
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return rowsErr
+	}
+	if i != len(movies) {
+		return errors.New("batch insert returned fewer rows than expected")
+	}
+	payloads := make([]any, len(movies))
+	for i, movie := range movies {
+		payloads[i] = movie
+	}
+	return writeOutbox(ctx, b.tx, EventMovieCreated, payloads...)
+}
+
+func (b *MovieBatch) Commit() error {
+	return b.tx.Commit()
+}
+
+func (b *MovieBatch) Rollback() error {
+	err := b.tx.Rollback()
+	if errors.Is(err, sql.ErrTxDone) {
+		return nil
+	}
+	return err
+}
+
+func (m MovieModel) Stream(criteria MovieCriteria, fn func(*Movie) error) error {
+	where, args := criteria.whereClause()
+	query := fmt.Sprintf(`
+        SELECT
+            id,
+            created_at,
+            title,
+            year,
+            runtime,
+            genres,
+            version
+        FROM
+            movies
+        WHERE%s
+        ORDER BY
+            id ASC
+    `, where)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	q, done, err := m.queryerFor(ctx, criteria)
+	if err != nil {
+		return err
+	}
+	defer done()
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var movie Movie
+		scanErr := rows.Scan(
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Version,
+		)
+		if scanErr != nil {
+			return scanErr
+		}
+		fnErr := fn(&movie)
+		if fnErr != nil {
+			return fnErr
+		}
+	}
+	return rows.Err()
 }
@@ -0,0 +1,333 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.gustavosantos.net/internal/validator"
+)
+
+const SubscriptionKindGenre = "genre"
+
+type Subscription struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type NotificationPreferences struct {
+	DigestEnabled bool           `json:"digest_enabled"`
+	LastDigestAt  *time.Time     `json:"last_digest_at,omitempty"`
+	Subscriptions []Subscription `json:"subscriptions"`
+}
+
+type DigestItem struct {
+	MovieID int64  `json:"movie_id"`
+	Title   string `json:"title"`
+	Year    int32  `json:"year"`
+	Reason  string `json:"reason"`
+}
+
+type DigestClaim struct {
+	UserID   int64
+	Items    []*DigestItem
+	previous sql.NullTime
+}
+
+func ValidateSubscriptions(v *validator.Validator, subscriptions []Subscription, genres GenreSet) {
+	v.Check(len(subscriptions) <= 50, "subscriptions", "must not contain more than 50 subscriptions")
+	seen := make(map[Subscription]bool)
+	for i, subscription := range subscriptions {
+		v.Check(subscription.Kind == SubscriptionKindGenre, "subscriptions", "kind must be genre")
+		if subscription.Kind == SubscriptionKindGenre && genres != nil {
+			slug, ok := genres.Resolve(subscription.Value)
+			v.Check(ok, "subscriptions", "unknown genre "+subscription.Value)
+			if ok {
+				subscriptions[i].Value = slug
+			}
+		}
+		v.Check(!seen[subscriptions[i]], "subscriptions", "must not contain duplicate values")
+		seen[subscriptions[i]] = true
+	}
+}
+
+type NotificationModel struct {
+	DB *sql.DB
+}
+
+func (m NotificationModel) GetPreferences(userID int64) (*NotificationPreferences, error) {
+	preferences := NotificationPreferences{DigestEnabled: true, Subscriptions: []Subscription{}}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var lastDigestAt sql.NullTime
+	err := m.DB.QueryRowContext(ctx, `
+        SELECT digest_enabled, last_digest_at
+        FROM notification_preferences
+        WHERE user_id = $1
+    `, userID).Scan(&preferences.DigestEnabled, &lastDigestAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if lastDigestAt.Valid {
+		preferences.LastDigestAt = &lastDigestAt.Time
+	}
+	rows, err := m.DB.QueryContext(ctx, `
+        SELECT kind, value
+        FROM subscriptions
+        WHERE user_id = $1
+        ORDER BY kind, value
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var subscription Subscription
+		err := rows.Scan(&subscription.Kind, &subscription.Value)
+		if err != nil {
+			return nil, err
+		}
+		preferences.Subscriptions = append(preferences.Subscriptions, subscription)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return &preferences, nil
+}
+
+func (m NotificationModel) UpdatePreferences(userID int64, preferences *NotificationPreferences) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO notification_preferences (user_id, digest_enabled)
+        VALUES ($1, $2)
+        ON CONFLICT (user_id) DO UPDATE SET digest_enabled = excluded.digest_enabled
+    `, userID, preferences.DigestEnabled)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM subscriptions WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	kinds := make([]string, len(preferences.Subscriptions))
+	values := make([]string, len(preferences.Subscriptions))
+	for i, subscription := range preferences.Subscriptions {
+		kinds[i] = subscription.Kind
+		values[i] = subscription.Value
+	}
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO subscriptions (user_id, kind, value)
+        SELECT $1, kind, value
+        FROM unnest($2::text[], $3::text[]) AS subscription(kind, value)
+    `, userID, pq.Array(kinds), pq.Array(values))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m NotificationModel) DisableDigest(userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, `
+        INSERT INTO notification_preferences (user_id, digest_enabled)
+        SELECT id, false
+        FROM users
+        WHERE id = $1
+        ON CONFLICT (user_id) DO UPDATE SET digest_enabled = false
+    `, userID)
+	return err
+}
+
+func (m NotificationModel) EnqueueForMovie(movie *Movie) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return enqueueNotifications(ctx, m.DB, movie)
+}
+
+func (m NotificationModel) EnqueueForMovieTx(ctx context.Context, tx *sql.Tx, movie *Movie) (int64, error) {
+	return enqueueNotifications(ctx, tx, movie)
+}
+
+func enqueueNotifications(ctx context.Context, db execer, movie *Movie) (int64, error) {
+	query := `
+        INSERT INTO notifications (
+            user_id,
+            movie_id,
+            reason
+        )
+        SELECT DISTINCT ON (subscriptions.user_id)
+            subscriptions.user_id,
+            $1,
+            subscriptions.kind || ':' || subscriptions.value
+        FROM
+            subscriptions
+            INNER JOIN users ON users.id = subscriptions.user_id
+        WHERE
+            users.activated
+            AND subscriptions.kind = 'genre'
+            AND subscriptions.value = ANY($2)
+        ORDER BY
+            subscriptions.user_id,
+            subscriptions.value
+        ON CONFLICT (user_id, movie_id) DO NOTHING
+    `
+	result, err := db.ExecContext(ctx, query, movie.ID, pq.Array(movie.Genres))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (m NotificationModel) DigestRecipients(ctx context.Context, period time.Duration, limit int) ([]int64, error) {
+	query := `
+        SELECT DISTINCT
+            notifications.user_id
+        FROM
+            notifications
+            INNER JOIN users ON users.id = notifications.user_id
+            LEFT JOIN notification_preferences ON notification_preferences.user_id = notifications.user_id
+        WHERE
+            notifications.digested_at IS NULL
+            AND users.activated
+            AND COALESCE(notification_preferences.digest_enabled, true)
+            AND (
+                notification_preferences.last_digest_at IS NULL
+                OR notification_preferences.last_digest_at < now() - make_interval(secs => $1)
+            )
+        LIMIT $2
+    `
+	rows, err := m.DB.QueryContext(ctx, query, period.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	userIDs := []int64{}
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return userIDs, nil
+}
+
+func (m NotificationModel) ClaimDigest(ctx context.Context, userID int64, period time.Duration) (*DigestClaim, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	claim := &DigestClaim{UserID: userID}
+	err = tx.QueryRowContext(ctx, `
+        SELECT last_digest_at
+        FROM notification_preferences
+        WHERE user_id = $1
+        FOR UPDATE
+    `, userID).Scan(&claim.previous)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	var claimed int64
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO notification_preferences (user_id, last_digest_at)
+        VALUES ($1, now())
+        ON CONFLICT (user_id) DO UPDATE SET last_digest_at = now()
+        WHERE notification_preferences.digest_enabled
+        AND (
+            notification_preferences.last_digest_at IS NULL
+            OR notification_preferences.last_digest_at < now() - make_interval(secs => $2)
+        )
+        RETURNING user_id
+    `, userID, period.Seconds()).Scan(&claimed)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+	rows, err := tx.QueryContext(ctx, `
+        WITH digested AS (
+            UPDATE notifications
+            SET digested_at = now()
+            WHERE user_id = $1
+            AND digested_at IS NULL
+            RETURNING movie_id, reason
+        )
+        SELECT
+            movies.id,
+            movies.title,
+            movies.year,
+            digested.reason
+        FROM
+            digested
+            INNER JOIN movies ON movies.id = digested.movie_id
+        ORDER BY
+            movies.id
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	claim.Items = []*DigestItem{}
+	for rows.Next() {
+		var item DigestItem
+		err := rows.Scan(&item.MovieID, &item.Title, &item.Year, &item.Reason)
+		if err != nil {
+			return nil, err
+		}
+		claim.Items = append(claim.Items, &item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+func (m NotificationModel) ReleaseDigest(ctx context.Context, claim *DigestClaim) error {
+	movieIDs := make([]int64, len(claim.Items))
+	for i, item := range claim.Items {
+		movieIDs[i] = item.MovieID
+	}
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, `
+        UPDATE notifications
+        SET digested_at = NULL
+        WHERE user_id = $1
+        AND movie_id = ANY($2)
+    `, claim.UserID, pq.Array(movieIDs))
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+        UPDATE notification_preferences
+        SET last_digest_at = $2
+        WHERE user_id = $1
+    `, claim.UserID, claim.previous)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,284 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	TopicUserRegistered         = "user.registered"
+	TopicActivationRequested    = "user.activation_requested"
+	TopicPasswordResetRequested = "user.password_reset_requested"
+)
+
+const (
+	OutboxStatusPending   = "pending"
+	OutboxStatusPublished = "published"
+	OutboxStatusFailed    = "failed"
+)
+
+type OutboxEntry struct {
+	ID            int64           `json:"id"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Topic         string          `json:"topic"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	PublishedAt   *time.Time      `json:"published_at,omitempty"`
+	LastError     string          `json:"last_error,omitempty"`
+	ConsumedBy    []string        `json:"consumed_by"`
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func writeOutbox(ctx context.Context, db execer, topic string, payloads ...any) error {
+	encoded := make([]string, len(payloads))
+	for i, payload := range payloads {
+		js, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		encoded[i] = string(js)
+	}
+	query := `
+        INSERT INTO outbox (
+            topic,
+            payload
+        )
+        SELECT
+            $1,
+            payload
+        FROM
+            unnest($2::jsonb[]) AS payload
+    `
+	_, err := db.ExecContext(ctx, query, topic, pq.Array(encoded))
+	return err
+}
+
+type OutboxModel struct {
+	DB *sql.DB
+}
+
+func (m OutboxModel) Publish(topic string, payloads ...any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return writeOutbox(ctx, m.DB, topic, payloads...)
+}
+
+func (m OutboxModel) Claim(ctx context.Context, limit int, lease time.Duration) ([]*OutboxEntry, error) {
+	query := `
+        UPDATE
+            outbox
+        SET
+            attempts = attempts + 1,
+            next_attempt_at = now() + make_interval(secs => $2)
+        WHERE
+            id IN (
+                SELECT id
+                FROM outbox
+                WHERE status = 'pending'
+                AND next_attempt_at <= now()
+                ORDER BY id
+                LIMIT $1
+                FOR UPDATE SKIP LOCKED
+            )
+        RETURNING
+            id,
+            created_at,
+            topic,
+            payload,
+            attempts
+    `
+	rows, err := m.DB.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []*OutboxEntry{}
+	for rows.Next() {
+		entry := OutboxEntry{Status: OutboxStatusPending}
+		err := rows.Scan(
+			&entry.ID,
+			&entry.CreatedAt,
+			&entry.Topic,
+			&entry.Payload,
+			&entry.Attempts,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (m OutboxModel) Consume(ctx context.Context, entryID int64, consumer string, fn func(tx *sql.Tx) error) error {
+	query := `
+        INSERT INTO outbox_consumers (
+            outbox_id,
+            consumer
+        )
+        VALUES (
+            $1,
+            $2
+        )
+        ON CONFLICT DO NOTHING
+    `
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	result, err := tx.ExecContext(ctx, query, entryID, consumer)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return nil
+	}
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m OutboxModel) RecordAttempt(ctx context.Context, entry *OutboxEntry, attemptErr string, retryAfter time.Duration) error {
+	query := `
+        UPDATE
+            outbox
+        SET
+            status = $2,
+            last_error = $3,
+            next_attempt_at = now() + make_interval(secs => $4),
+            published_at = CASE WHEN $2 = 'published' THEN now() END
+        WHERE
+            id = $1
+    `
+	args := []any{entry.ID, entry.Status, attemptErr, retryAfter.Seconds()}
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (m OutboxModel) Stuck(olderThan time.Duration, filters Filters) ([]*OutboxEntry, Metadata, error) {
+	query := `
+        SELECT
+            count(*) OVER(),
+            outbox.id,
+            outbox.created_at,
+            outbox.topic,
+            outbox.payload,
+            outbox.status,
+            outbox.attempts,
+            outbox.next_attempt_at,
+            outbox.last_error,
+            ARRAY(
+                SELECT consumer
+                FROM outbox_consumers
+                WHERE outbox_id = outbox.id
+                ORDER BY consumer
+            )
+        FROM
+            outbox
+        WHERE
+            outbox.status = 'failed'
+            OR (outbox.status = 'pending' AND outbox.created_at < now() - make_interval(secs => $1))
+        ORDER BY
+            outbox.id ASC
+        LIMIT $2
+        OFFSET $3
+    `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, olderThan.Seconds(), filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	entries := []*OutboxEntry{}
+	for rows.Next() {
+		var entry OutboxEntry
+		err := rows.Scan(
+			&totalRecords,
+			&entry.ID,
+			&entry.CreatedAt,
+			&entry.Topic,
+			&entry.Payload,
+			&entry.Status,
+			&entry.Attempts,
+			&entry.NextAttemptAt,
+			&entry.LastError,
+			pq.Array(&entry.ConsumedBy),
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		entries = append(entries, &entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return entries, metadata, nil
+}
+
+func (m OutboxModel) Retry(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `
+        UPDATE
+            outbox
+        SET
+            status = 'pending',
+            attempts = 0,
+            next_attempt_at = now()
+        WHERE
+            id = $1
+            AND status <> 'published'
+    `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m OutboxModel) DeletePublished(ctx context.Context, olderThan time.Duration) (int64, error) {
+	query := `
+        DELETE FROM
+            outbox
+        WHERE
+            status = 'published'
+            AND published_at < now() - make_interval(secs => $1)
+    `
+	result, err := m.DB.ExecContext(ctx, query, olderThan.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,185 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var TrendingWindows = map[string]struct {
+	Period   time.Duration
+	HalfLife time.Duration
+}{
+	"day":  {Period: 24 * time.Hour, HalfLife: 6 * time.Hour},
+	"week": {Period: 7 * 24 * time.Hour, HalfLife: 48 * time.Hour},
+}
+
+const movieViewsRetention = 30 * 24 * time.Hour
+
+type PopularityModel struct {
+	DB *sql.DB
+}
+
+func (m PopularityModel) RecordViews(ctx context.Context, counts map[int64]int64, bucket time.Time) error {
+	if len(counts) == 0 {
+		return nil
+	}
+	ids := make([]int64, 0, len(counts))
+	views := make([]int64, 0, len(counts))
+	for id, n := range counts {
+		ids = append(ids, id)
+		views = append(views, n)
+	}
+	query := `
+        INSERT INTO movie_views (
+            movie_id,
+            bucket,
+            views
+        )
+        SELECT
+            counted.movie_id,
+            $3,
+            counted.views
+        FROM
+            unnest($1::bigint[], $2::bigint[]) AS counted(movie_id, views)
+            INNER JOIN movies ON movies.id = counted.movie_id
+        ON CONFLICT (movie_id, bucket) DO UPDATE
+        SET views = movie_views.views + excluded.views
+    `
+	_, err := m.DB.ExecContext(ctx, query, pq.Array(ids), pq.Array(views), bucket.Truncate(time.Hour))
+	return err
+}
+
+func (m PopularityModel) Refresh(ctx context.Context) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, `DELETE FROM movie_views WHERE bucket < $1`, time.Now().Add(-movieViewsRetention))
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(TrendingWindows))
+	for name := range TrendingWindows {
+		names = append(names, name)
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM movie_popularity WHERE time_window <> ALL($1)`, pq.Array(names))
+	if err != nil {
+		return err
+	}
+	query := `
+        WITH scored AS (
+            SELECT
+                movie_id,
+                sum(views * power(0.5, extract(epoch FROM now() - bucket) / $3)) AS score
+            FROM
+                movie_views
+            WHERE
+                bucket > now() - make_interval(secs => $2)
+            GROUP BY
+                movie_id
+        ),
+        stale AS (
+            DELETE FROM
+                movie_popularity
+            WHERE
+                time_window = $1
+                AND movie_id NOT IN (SELECT movie_id FROM scored)
+        )
+        INSERT INTO movie_popularity (
+            time_window,
+            movie_id,
+            score
+        )
+        SELECT
+            $1,
+            movie_id,
+            score
+        FROM
+            scored
+        ON CONFLICT (time_window, movie_id) DO UPDATE
+        SET
+            score = excluded.score,
+            computed_at = NOW()
+    `
+	for name, window := range TrendingWindows {
+		_, err = tx.ExecContext(ctx, query, name, window.Period.Seconds(), window.HalfLife.Seconds())
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (m PopularityModel) Trending(window string, filters Filters) ([]*Movie, Metadata, error) {
+	return m.list(`
+            movies
+            INNER JOIN movie_popularity ON movie_popularity.movie_id = movies.id
+        WHERE
+            movie_popularity.time_window = $3`,
+		"movie_popularity.score DESC, movies.id ASC", filters, window)
+}
+
+func (m PopularityModel) Recent(updated bool, filters Filters) ([]*Movie, Metadata, error) {
+	orderBy := "movies.created_at DESC, movies.id DESC"
+	if updated {
+		orderBy = "movies.updated_at DESC, movies.id DESC"
+	}
+	return m.list(`
+            movies`, orderBy, filters)
+}
+
+func (m PopularityModel) list(from, orderBy string, filters Filters, args ...any) ([]*Movie, Metadata, error) {
+	query := fmt.Sprintf(`
+        SELECT
+            count(*) OVER(),
+            movies.id,
+            movies.created_at,
+            movies.title,
+            movies.year,
+            movies.runtime,
+            movies.genres,
+            movies.version
+        FROM%s
+        ORDER BY
+            %s
+        LIMIT $1
+        OFFSET $2
+    `, from, orderBy)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	args = append([]any{filters.limit(), filters.offset()}, args...)
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	movies := []*Movie{}
+	for rows.Next() {
+		var movie Movie
+		err := rows.Scan(
+			&totalRecords,
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		movies = append(movies, &movie)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return movies, metadata, nil
+}
@@ -0,0 +1,327 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.gustavosantos.net/internal/validator"
+)
+
+const (
+	RecommendationSourceCollaborative = "collaborative"
+	RecommendationSourceGenres        = "genres"
+)
+
+const (
+	recommendationHistoryLimit = 100
+	recommendationNeighbours   = 50
+)
+
+type WatchHistoryEntry struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"-"`
+	UserID    int64     `json:"-"`
+	MovieID   int64     `json:"movie_id"`
+	WatchedAt time.Time `json:"watched_at"`
+	Rating    *int      `json:"rating,omitempty"`
+}
+
+type Recommendation struct {
+	*Movie
+	Score float64 `json:"score"`
+}
+
+func ValidateWatchHistoryEntry(v *validator.Validator, entry *WatchHistoryEntry) {
+	v.Check(entry.MovieID > 0, "movie_id", "must be provided")
+	v.Check(!entry.WatchedAt.After(time.Now().Add(time.Minute)), "watched_at", "must not be in the future")
+	v.Check(entry.WatchedAt.Year() >= 1888, "watched_at", "must be a valid timestamp")
+	if entry.Rating != nil {
+		v.Check(*entry.Rating >= 1 && *entry.Rating <= 10, "rating", "must be between 1 and 10")
+	}
+}
+
+type RecommendationModel struct {
+	DB *sql.DB
+}
+
+func (m RecommendationModel) InsertHistory(entry *WatchHistoryEntry) error {
+	query := `
+        INSERT INTO watch_history (
+            user_id,
+            movie_id,
+            watched_at,
+            rating
+        )
+        VALUES (
+            $1,
+            $2,
+            $3,
+            $4
+        )
+        RETURNING
+            id,
+            created_at
+    `
+	args := []any{entry.UserID, entry.MovieID, entry.WatchedAt, entry.Rating}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		switch {
+		case violatesConstraint(err, foreignKeyViolation, "watch_history_movie_id_fkey"):
+			return ErrUnknownMovie
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m RecommendationModel) GetForUser(userID int64, filters Filters) ([]*Recommendation, string, Metadata, error) {
+	recommendations, metadata, err := m.scan(`
+        SELECT
+            count(*) OVER(),
+            movies.id,
+            movies.created_at,
+            movies.title,
+            movies.year,
+            movies.runtime,
+            movies.genres,
+            movies.version,
+            user_recommendations.score
+        FROM
+            user_recommendations
+            INNER JOIN movies ON movies.id = user_recommendations.movie_id
+        WHERE
+            user_recommendations.user_id = $1
+        ORDER BY
+            user_recommendations.score DESC,
+            movies.id ASC
+        LIMIT $2
+        OFFSET $3
+    `, userID, filters)
+	if err != nil || len(recommendations) > 0 || filters.Page > 1 {
+		return recommendations, RecommendationSourceCollaborative, metadata, err
+	}
+	recommendations, metadata, err = m.scan(`
+        WITH preferences AS (
+            SELECT
+                genre,
+                count(*)::double precision / sum(count(*)) OVER () AS weight
+            FROM
+                watch_history
+                INNER JOIN movies ON movies.id = watch_history.movie_id,
+                unnest(movies.genres) AS genre
+            WHERE
+                watch_history.user_id = $1
+            GROUP BY
+                genre
+        ),
+        popularity AS (
+            SELECT
+                movie_id,
+                count(DISTINCT user_id) AS viewers
+            FROM
+                watch_history
+            GROUP BY
+                movie_id
+        )
+        SELECT
+            count(*) OVER(),
+            movies.id,
+            movies.created_at,
+            movies.title,
+            movies.year,
+            movies.runtime,
+            movies.genres,
+            movies.version,
+            COALESCE((SELECT sum(weight) FROM preferences WHERE genre = ANY(movies.genres)), 0) AS score
+        FROM
+            movies
+            LEFT JOIN popularity ON popularity.movie_id = movies.id
+        WHERE
+            NOT EXISTS (
+                SELECT 1
+                FROM watch_history
+                WHERE watch_history.user_id = $1
+                AND watch_history.movie_id = movies.id
+            )
+        ORDER BY
+            score DESC,
+            COALESCE(popularity.viewers, 0) DESC,
+            movies.id ASC
+        LIMIT $2
+        OFFSET $3
+    `, userID, filters)
+	return recommendations, RecommendationSourceGenres, metadata, err
+}
+
+func (m RecommendationModel) scan(query string, userID int64, filters Filters) ([]*Recommendation, Metadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, userID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	recommendations := []*Recommendation{}
+	for rows.Next() {
+		recommendation := Recommendation{Movie: &Movie{}}
+		err := rows.Scan(
+			&totalRecords,
+			&recommendation.ID,
+			&recommendation.CreatedAt,
+			&recommendation.Title,
+			&recommendation.Year,
+			&recommendation.Runtime,
+			pq.Array(&recommendation.Genres),
+			&recommendation.Version,
+			&recommendation.Score,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		recommendations = append(recommendations, &recommendation)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return recommendations, metadata, nil
+}
+
+func (m RecommendationModel) Refresh(ctx context.Context, perUser int) (int64, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	var locked bool
+	err = tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock(hashtext('user_recommendations'))`).Scan(&locked)
+	if err != nil || !locked {
+		return 0, err
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM user_recommendations`)
+	if err != nil {
+		return 0, err
+	}
+	query := `
+        WITH history AS (
+            SELECT
+                user_id,
+                movie_id,
+                max(COALESCE(rating, 7))::double precision / 10 AS weight,
+                row_number() OVER (PARTITION BY user_id ORDER BY max(watched_at) DESC, movie_id ASC) AS recency
+            FROM
+                watch_history
+            GROUP BY
+                user_id,
+                movie_id
+        ),
+        interactions AS (
+            SELECT
+                user_id,
+                movie_id,
+                weight
+            FROM
+                history
+            WHERE
+                recency <= $2
+        ),
+        popularity AS (
+            SELECT
+                movie_id,
+                count(*) AS viewers
+            FROM
+                interactions
+            GROUP BY
+                movie_id
+        ),
+        pairs AS (
+            SELECT
+                a.movie_id AS movie_a,
+                b.movie_id AS movie_b,
+                sum(a.weight * b.weight) / sqrt(pa.viewers * pb.viewers) AS score
+            FROM
+                interactions AS a
+                INNER JOIN interactions AS b ON b.user_id = a.user_id AND b.movie_id <> a.movie_id
+                INNER JOIN popularity AS pa ON pa.movie_id = a.movie_id
+                INNER JOIN popularity AS pb ON pb.movie_id = b.movie_id
+            GROUP BY
+                a.movie_id,
+                b.movie_id,
+                pa.viewers,
+                pb.viewers
+        ),
+        similarity AS (
+            SELECT
+                movie_a,
+                movie_b,
+                score
+            FROM (
+                SELECT
+                    movie_a,
+                    movie_b,
+                    score,
+                    row_number() OVER (PARTITION BY movie_a ORDER BY score DESC, movie_b ASC) AS position
+                FROM
+                    pairs
+            ) AS neighbours
+            WHERE
+                position <= $3
+        ),
+        scored AS (
+            SELECT
+                interactions.user_id,
+                similarity.movie_b AS movie_id,
+                sum(interactions.weight * similarity.score) AS score
+            FROM
+                interactions
+                INNER JOIN similarity ON similarity.movie_a = interactions.movie_id
+            WHERE
+                NOT EXISTS (
+                    SELECT 1
+                    FROM interactions AS seen
+                    WHERE seen.user_id = interactions.user_id
+                    AND seen.movie_id = similarity.movie_b
+                )
+            GROUP BY
+                interactions.user_id,
+                similarity.movie_b
+        ),
+        ranked AS (
+            SELECT
+                user_id,
+                movie_id,
+                score,
+                row_number() OVER (PARTITION BY user_id ORDER BY score DESC, movie_id ASC) AS position
+            FROM
+                scored
+        )
+        INSERT INTO user_recommendations (
+            user_id,
+            movie_id,
+            score
+        )
+        SELECT
+            user_id,
+            movie_id,
+            score
+        FROM
+            ranked
+        WHERE
+            position <= $1
+    `
+	result, err := tx.ExecContext(ctx, query, perUser, recommendationHistoryLimit, recommendationNeighbours)
+	if err != nil {
+		return 0, err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return inserted, tx.Commit()
+}
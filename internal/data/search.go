@@ -0,0 +1,146 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.gustavosantos.net/internal/validator"
+)
+
+var SearchLanguages = map[string]string{
+	"simple": "simple",
+	"en":     "english",
+	"pt":     "portuguese",
+	"es":     "spanish",
+}
+
+type MovieSearchResult struct {
+	*Movie
+	Rank      float32 `json:"rank"`
+	Highlight string  `json:"highlight"`
+}
+
+func ValidateSearch(v *validator.Validator, query, language string) {
+	v.Check(query != "", "q", "must be provided")
+	v.Check(len(query) <= 500, "q", "must not be more than 500 bytes long")
+	_, ok := SearchLanguages[language]
+	v.Check(ok, "lang", "unsupported language")
+}
+
+func (m MovieModel) Search(query, language string, criteria MovieCriteria, filters Filters) ([]*MovieSearchResult, Metadata, error) {
+	where, args := criteria.whereClause()
+	queryPosition, configPosition := len(args)+1, len(args)+2
+	sqlQuery := fmt.Sprintf(`
+        SELECT
+            count(*) OVER(),
+            id,
+            created_at,
+            title,
+            year,
+            runtime,
+            genres,
+            version,
+            ts_rank(search_vector, query) AS rank,
+            ts_headline($%d::regconfig, title, query, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true') AS highlight
+        FROM
+            movies,
+            websearch_to_tsquery($%d::regconfig, $%d) query
+        WHERE%s
+            AND search_vector @@ query
+        ORDER BY
+            %s
+        LIMIT $%d
+        OFFSET $%d
+    `, configPosition, configPosition, queryPosition, where, filters.orderBy(false), len(args)+3, len(args)+4)
+	args = append(args, query, SearchLanguages[language], filters.limit(), filters.offset())
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	q, done, err := m.queryerFor(ctx, criteria)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer done()
+	rows, err := q.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	results := []*MovieSearchResult{}
+	for rows.Next() {
+		result := MovieSearchResult{Movie: &Movie{}}
+		err := rows.Scan(
+			&totalRecords,
+			&result.ID,
+			&result.CreatedAt,
+			&result.Title,
+			&result.Year,
+			&result.Runtime,
+			pq.Array(&result.Genres),
+			&result.Version,
+			&result.Rank,
+			&result.Highlight,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		results = append(results, &result)
+	}
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return nil, Metadata{}, rowsErr
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	metadata.HasNextPage = filters.Page < metadata.LastPage
+	return results, metadata, nil
+}
+
+type MovieSuggestion struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+	Year  int32  `json:"year"`
+}
+
+func (m MovieModel) Suggest(prefix string, limit int) ([]*MovieSuggestion, error) {
+	query := `
+        SELECT
+            id,
+            title,
+            year
+        FROM
+            movies
+        WHERE
+            title ILIKE $1
+            OR $2 <% title
+        ORDER BY
+            title ILIKE $3 DESC,
+            word_similarity($2, title) DESC,
+            title ASC,
+            id ASC
+        LIMIT $4
+    `
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix)
+	args := []any{"%" + escaped + "%", prefix, escaped + "%", limit}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	suggestions := []*MovieSuggestion{}
+	for rows.Next() {
+		var suggestion MovieSuggestion
+		err := rows.Scan(&suggestion.ID, &suggestion.Title, &suggestion.Year)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, &suggestion)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,144 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type SimilarMovie struct {
+	*Movie
+	Score float64 `json:"score"`
+}
+
+type SimilarityScorer interface {
+	Score(movie, candidate *Movie) float64
+}
+
+type GenreJaccardScorer struct{}
+
+func (GenreJaccardScorer) Score(movie, candidate *Movie) float64 {
+	genres := make(map[string]bool, len(movie.Genres))
+	for _, genre := range movie.Genres {
+		genres[genre] = true
+	}
+	shared := 0
+	union := len(genres)
+	for _, genre := range candidate.Genres {
+		if genres[genre] {
+			shared++
+		} else {
+			union++
+		}
+	}
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
+
+type YearProximityScorer struct {
+	Span int32
+}
+
+func (s YearProximityScorer) Score(movie, candidate *Movie) float64 {
+	distance := math.Abs(float64(movie.Year - candidate.Year))
+	return math.Max(0, 1-distance/float64(s.Span))
+}
+
+type RuntimeProximityScorer struct {
+	Span Runtime
+}
+
+func (s RuntimeProximityScorer) Score(movie, candidate *Movie) float64 {
+	distance := math.Abs(float64(movie.Runtime - candidate.Runtime))
+	return math.Max(0, 1-distance/float64(s.Span))
+}
+
+type WeightedScorer struct {
+	Scorer SimilarityScorer
+	Weight float64
+}
+
+type CompositeScorer []WeightedScorer
+
+func (c CompositeScorer) Score(movie, candidate *Movie) float64 {
+	var score, total float64
+	for _, weighted := range c {
+		score += weighted.Weight * weighted.Scorer.Score(movie, candidate)
+		total += weighted.Weight
+	}
+	if total == 0 {
+		return 0
+	}
+	return score / total
+}
+
+func DefaultSimilarityScorer() SimilarityScorer {
+	return CompositeScorer{
+		{Scorer: GenreJaccardScorer{}, Weight: 0.6},
+		{Scorer: YearProximityScorer{Span: 20}, Weight: 0.25},
+		{Scorer: RuntimeProximityScorer{Span: 60}, Weight: 0.15},
+	}
+}
+
+func PaginateSimilar(similar []*SimilarMovie, filters Filters) ([]*SimilarMovie, Metadata) {
+	metadata := calculateMetadata(len(similar), filters.Page, filters.PageSize)
+	start := min(filters.offset(), len(similar))
+	end := min(start+filters.limit(), len(similar))
+	return similar[start:end], metadata
+}
+
+func (m MovieModel) SimilarCandidates(movie *Movie, limit int) ([]*Movie, error) {
+	query := fmt.Sprintf(`
+        SELECT
+            id,
+            created_at,
+            title,
+            year,
+            runtime,
+            genres,
+            version
+        FROM
+            movies
+        WHERE
+            id <> $1
+            AND genres && $2
+        ORDER BY
+            cardinality(ARRAY(SELECT unnest(genres) INTERSECT SELECT unnest($2::text[]))) DESC,
+            abs(year - $3) ASC,
+            id ASC
+        LIMIT %d
+    `, limit)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, movie.ID, pq.Array(movie.Genres), movie.Year)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	movies := []*Movie{}
+	for rows.Next() {
+		var candidate Movie
+		err := rows.Scan(
+			&candidate.ID,
+			&candidate.CreatedAt,
+			&candidate.Title,
+			&candidate.Year,
+			&candidate.Runtime,
+			pq.Array(&candidate.Genres),
+			&candidate.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		movies = append(movies, &candidate)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return movies, nil
+}
@@ -0,0 +1,91 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type RuntimePercentiles struct {
+	P25 float64 `json:"p25"`
+	P50 float64 `json:"p50"`
+	P75 float64 `json:"p75"`
+	P90 float64 `json:"p90"`
+}
+
+type RecentlyAdded struct {
+	Last7Days   int `json:"last_7_days"`
+	Last30Days  int `json:"last_30_days"`
+	Last365Days int `json:"last_365_days"`
+}
+
+type MovieStats struct {
+	Total              int                `json:"total"`
+	AverageRuntime     float64            `json:"average_runtime"`
+	RuntimePercentiles RuntimePercentiles `json:"runtime_percentiles"`
+	RecentlyAdded      RecentlyAdded      `json:"recently_added"`
+	Years              []FacetCount       `json:"years"`
+	Decades            []FacetCount       `json:"decades"`
+	Genres             []FacetCount       `json:"genres"`
+}
+
+func (m MovieModel) Stats(criteria MovieCriteria) (*MovieStats, error) {
+	where, args := criteria.whereClause()
+	query := fmt.Sprintf(`
+        SELECT
+            count(*),
+            COALESCE(avg(runtime), 0),
+            percentile_cont(ARRAY[0.25, 0.5, 0.75, 0.9]) WITHIN GROUP (ORDER BY runtime),
+            count(*) FILTER (WHERE created_at > now() - interval '7 days'),
+            count(*) FILTER (WHERE created_at > now() - interval '30 days'),
+            count(*) FILTER (WHERE created_at > now() - interval '365 days')
+        FROM
+            movies
+        WHERE%s
+    `, where)
+	var (
+		stats       MovieStats
+		percentiles []float64
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	q, done, err := m.queryerFor(ctx, criteria)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	err = q.QueryRowContext(ctx, query, args...).Scan(
+		&stats.Total,
+		&stats.AverageRuntime,
+		(*pq.Float64Array)(&percentiles),
+		&stats.RecentlyAdded.Last7Days,
+		&stats.RecentlyAdded.Last30Days,
+		&stats.RecentlyAdded.Last365Days,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(percentiles) == 4 {
+		stats.RuntimePercentiles = RuntimePercentiles{
+			P25: percentiles[0],
+			P50: percentiles[1],
+			P75: percentiles[2],
+			P90: percentiles[3],
+		}
+	}
+	stats.Years, err = m.facetCounts("year::text", "movies", criteria, "value ASC")
+	if err != nil {
+		return nil, err
+	}
+	stats.Decades, err = m.facetCounts("((year / 10) * 10)::text || 's'", "movies", criteria, "value ASC")
+	if err != nil {
+		return nil, err
+	}
+	stats.Genres, err = m.facetCounts("genre", "movies, unnest(movies.genres) AS genre", criteria, "count(*) DESC, value ASC")
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
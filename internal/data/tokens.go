@@ -60,7 +60,34 @@ func (m TokenModel) New(userID int64, ttl time.Duration, scope string) (*Token,
 	return token, insertErr
 }
 
+func (m TokenModel) Replace(ctx context.Context, userID int64, ttl time.Duration, scope string) (*Token, error) {
+	token, err := generateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, `DELETE FROM tokens WHERE scope = $1 AND user_id = $2`, scope, userID)
+	if err != nil {
+		return nil, err
+	}
+	err = insertToken(ctx, tx, token)
+	if err != nil {
+		return nil, err
+	}
+	return token, tx.Commit()
+}
+
 func (m TokenModel) Insert(token *Token) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return insertToken(ctx, m.DB, token)
+}
+
+func insertToken(ctx context.Context, db execer, token *Token) error {
 	query := `
         INSERT INTO tokens (
             hash,
@@ -76,9 +103,7 @@ func (m TokenModel) Insert(token *Token) error {
         );
     `
 	args := []any{token.Hash, token.UserID, token.Expiry, token.Scope}
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-	_, err := m.DB.ExecContext(ctx, query, args...)
+	_, err := db.ExecContext(ctx, query, args...)
 	return err
 }
 
@@ -92,3 +117,15 @@ func (m TokenModel) DeleteAllForUser(scope string, userID int64) error {
 	_, err := m.DB.ExecContext(ctx, query, scope, userID)
 	return err
 }
+
+func (m TokenModel) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `
+        DELETE FROM tokens
+        WHERE expiry < now();
+    `
+	result, err := m.DB.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,186 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.gustavosantos.net/internal/validator"
+)
+
+var LocaleRX = regexp.MustCompile("^[a-z]{2,3}(?:-[A-Z]{2})?$")
+
+type MovieTranslation struct {
+	MovieID   int64     `json:"movie_id"`
+	Locale    string    `json:"locale"`
+	CreatedAt time.Time `json:"-"`
+	Title     string    `json:"title"`
+	Overview  string    `json:"overview,omitempty"`
+	Version   int32     `json:"version"`
+}
+
+func ValidateMovieTranslation(v *validator.Validator, translation *MovieTranslation) {
+	v.Check(validator.Matches(translation.Locale, LocaleRX), "locale", "must be a language tag such as pt or pt-BR")
+	v.Check(translation.Title != "", "title", "must be provided")
+	v.Check(len(translation.Title) <= 500, "title", "must not be more than 500 bytes long")
+	v.Check(len(translation.Overview) <= 5000, "overview", "must not be more than 5000 bytes long")
+}
+
+type MovieTranslationModel struct {
+	DB *sql.DB
+}
+
+func (m MovieTranslationModel) GetAllForMovie(movieID int64) ([]*MovieTranslation, error) {
+	query := `
+        SELECT
+            movie_id,
+            locale,
+            created_at,
+            title,
+            overview,
+            version
+        FROM
+            movie_translations
+        WHERE
+            movie_id = $1
+        ORDER BY
+            locale
+    `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	translations := []*MovieTranslation{}
+	for rows.Next() {
+		var translation MovieTranslation
+		err := rows.Scan(
+			&translation.MovieID,
+			&translation.Locale,
+			&translation.CreatedAt,
+			&translation.Title,
+			&translation.Overview,
+			&translation.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		translations = append(translations, &translation)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return translations, nil
+}
+
+func (m MovieTranslationModel) Upsert(translation *MovieTranslation) error {
+	query := `
+        INSERT INTO movie_translations (
+            movie_id,
+            locale,
+            title,
+            overview
+        )
+        VALUES (
+            $1,
+            $2,
+            $3,
+            $4
+        )
+        ON CONFLICT (movie_id, locale) DO UPDATE
+        SET
+            title = EXCLUDED.title,
+            overview = EXCLUDED.overview,
+            version = movie_translations.version + 1
+        RETURNING
+            created_at,
+            version
+    `
+	args := []any{translation.MovieID, translation.Locale, translation.Title, translation.Overview}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&translation.CreatedAt, &translation.Version)
+}
+
+func (m MovieTranslationModel) Delete(movieID int64, locale string) error {
+	query := `
+        DELETE FROM
+            movie_translations
+        WHERE
+            movie_id = $1
+            AND locale = $2
+    `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, movieID, locale)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m MovieTranslationModel) Localize(locales []string, movies ...*Movie) error {
+	if len(locales) == 0 || len(movies) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(movies))
+	byID := make(map[int64]*Movie, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.ID
+		byID[movie.ID] = movie
+	}
+	query := `
+        SELECT DISTINCT ON (movie_id)
+            movie_id,
+            locale,
+            title,
+            overview
+        FROM
+            movie_translations
+        WHERE
+            movie_id = ANY($1)
+            AND locale = ANY($2)
+        ORDER BY
+            movie_id,
+            array_position($2, locale)
+    `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids), pq.Array(locales))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var translation MovieTranslation
+		err := rows.Scan(&translation.MovieID, &translation.Locale, &translation.Title, &translation.Overview)
+		if err != nil {
+			return err
+		}
+		translation.apply(byID[translation.MovieID])
+	}
+	return rows.Err()
+}
+
+func (t *MovieTranslation) apply(movie *Movie) {
+	if movie == nil {
+		return
+	}
+	movie.OriginalTitle = movie.Title
+	movie.Title = t.Title
+	if t.Overview != "" {
+		movie.Overview = t.Overview
+	}
+	movie.Locale = t.Locale
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"time"
 
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 	"greenlight.gustavosantos.net/internal/validator"
 )
@@ -110,7 +111,7 @@ func (m UserModel) Insert(user *User) error {
 	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
 	if err != nil {
 		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+		case violatesConstraint(err, uniqueViolation, "users_email_key"):
 			return ErrDuplicateEmail
 		default:
 			return err
@@ -224,7 +225,7 @@ func (m UserModel) Update(user *User) error {
 	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.Version)
 	if err != nil {
 		switch {
-		case err.Error() == `pq: duplicate key value violates unique costraint "users_email_key"`:
+		case violatesConstraint(err, uniqueViolation, "users_email_key"):
 			return ErrDuplicateEmail
 		default:
 			return err
@@ -267,3 +268,95 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error)
 	}
 	return &user, nil
 }
+
+func (m UserModel) Activate(user *User) error {
+	query := `
+        UPDATE
+            users
+        SET
+            activated = true,
+            version = version + 1
+        WHERE
+            id = $1
+            AND version = $2
+        RETURNING
+            version
+    `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = tx.QueryRowContext(ctx, query, user.ID, user.Version).Scan(&user.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	user.Activated = true
+	_, err = tx.ExecContext(ctx, `DELETE FROM tokens WHERE scope = $1 AND user_id = $2`, ScopeActivation, user.ID)
+	if err != nil {
+		return err
+	}
+	err = writeOutbox(ctx, tx, EventUserActivated, user)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m UserModel) Register(user *User, permissions ...string) error {
+	query := `
+        INSERT INTO users (
+            name,
+            email,
+            password_hash,
+            activated
+        )
+        VALUES (
+            $1,
+            $2,
+            $3,
+            $4
+        )
+        RETURNING
+            id,
+            created_at,
+            version
+    `
+	args := []any{user.Name, user.Email, user.Password.hash, user.Activated}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
+	if err != nil {
+		switch {
+		case violatesConstraint(err, uniqueViolation, "users_email_key"):
+			return ErrDuplicateEmail
+		default:
+			return err
+		}
+	}
+	query = `
+        INSERT INTO users_permissions
+        SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)
+    `
+	_, err = tx.ExecContext(ctx, query, user.ID, pq.Array(permissions))
+	if err != nil {
+		return err
+	}
+	err = writeOutbox(ctx, tx, TopicUserRegistered, user)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,474 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.gustavosantos.net/internal/validator"
+)
+
+const (
+	EventMovieCreated  = "movie.created"
+	EventMovieUpdated  = "movie.updated"
+	EventMovieDeleted  = "movie.deleted"
+	EventUserActivated = "user.activated"
+)
+
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusSucceeded = "succeeded"
+	DeliveryStatusFailed    = "failed"
+)
+
+var WebhookEvents = []string{
+	EventMovieCreated,
+	EventMovieUpdated,
+	EventMovieDeleted,
+	EventUserActivated,
+}
+
+type Webhook struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	Version   int32     `json:"version"`
+}
+
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	WebhookID      int64           `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	URL            string          `json:"-"`
+	Secret         string          `json:"-"`
+}
+
+func NewWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func ValidateWebhook(v *validator.Validator, webhook *Webhook) {
+	u, err := url.Parse(webhook.URL)
+	v.Check(webhook.URL != "", "url", "must be provided")
+	v.Check(err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != "", "url", "must be an absolute http or https URL")
+	v.Check(len(webhook.URL) <= 2000, "url", "must not be more than 2000 bytes long")
+	v.Check(len(webhook.Secret) >= 16, "secret", "must be at least 16 bytes long")
+	v.Check(len(webhook.Events) >= 1, "events", "must contain at least 1 event")
+	v.Check(validator.Unique(webhook.Events), "events", "must not contain duplicate values")
+	for _, event := range webhook.Events {
+		v.Check(validator.PermittedValue(event, WebhookEvents...), "events", fmt.Sprintf("unknown event %q", event))
+	}
+}
+
+type WebhookModel struct {
+	DB *sql.DB
+}
+
+func (m WebhookModel) Enqueue(ctx context.Context, tx *sql.Tx, event string, payload json.RawMessage) error {
+	query := `
+        INSERT INTO webhook_deliveries (
+            webhook_id,
+            event,
+            payload
+        )
+        SELECT
+            id,
+            $1,
+            $2
+        FROM
+            webhooks
+        WHERE
+            active
+            AND $1 = ANY(events)
+    `
+	_, err := tx.ExecContext(ctx, query, event, []byte(payload))
+	return err
+}
+
+func (m WebhookModel) GetAll() ([]*Webhook, error) {
+	query := `
+        SELECT
+            id,
+            created_at,
+            url,
+            events,
+            active,
+            version
+        FROM
+            webhooks
+        ORDER BY
+            id ASC
+    `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	webhooks := []*Webhook{}
+	for rows.Next() {
+		var webhook Webhook
+		err := rows.Scan(
+			&webhook.ID,
+			&webhook.CreatedAt,
+			&webhook.URL,
+			pq.Array(&webhook.Events),
+			&webhook.Active,
+			&webhook.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, &webhook)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (m WebhookModel) Get(id int64) (*Webhook, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+        SELECT
+            id,
+            created_at,
+            url,
+            secret,
+            events,
+            active,
+            version
+        FROM
+            webhooks
+        WHERE
+            id = $1
+    `
+	var webhook Webhook
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&webhook.ID,
+		&webhook.CreatedAt,
+		&webhook.URL,
+		&webhook.Secret,
+		pq.Array(&webhook.Events),
+		&webhook.Active,
+		&webhook.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &webhook, nil
+}
+
+func (m WebhookModel) Insert(webhook *Webhook) error {
+	query := `
+        INSERT INTO webhooks (
+            url,
+            secret,
+            events,
+            active
+        )
+        VALUES (
+            $1,
+            $2,
+            $3,
+            $4
+        )
+        RETURNING
+            id,
+            created_at,
+            version
+    `
+	args := []any{webhook.URL, webhook.Secret, pq.Array(webhook.Events), webhook.Active}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&webhook.ID, &webhook.CreatedAt, &webhook.Version)
+}
+
+func (m WebhookModel) Update(webhook *Webhook) error {
+	query := `
+        UPDATE
+            webhooks
+        SET
+            url = $1,
+            secret = $2,
+            events = $3,
+            active = $4,
+            version = version + 1
+        WHERE
+            id = $5
+            AND version = $6
+        RETURNING
+            version
+    `
+	args := []any{webhook.URL, webhook.Secret, pq.Array(webhook.Events), webhook.Active, webhook.ID, webhook.Version}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&webhook.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m WebhookModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `
+        DELETE FROM
+            webhooks
+        WHERE
+            id = $1
+    `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m WebhookModel) GetDeliveries(webhookID int64, filters Filters) ([]*WebhookDelivery, Metadata, error) {
+	query := `
+        SELECT
+            count(*) OVER(),
+            id,
+            created_at,
+            webhook_id,
+            event,
+            payload,
+            status,
+            attempts,
+            next_attempt_at,
+            last_attempt_at,
+            response_status,
+            last_error
+        FROM
+            webhook_deliveries
+        WHERE
+            webhook_id = $1
+        ORDER BY
+            id DESC
+        LIMIT $2
+        OFFSET $3
+    `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, webhookID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	deliveries := []*WebhookDelivery{}
+	for rows.Next() {
+		var (
+			delivery       WebhookDelivery
+			lastAttemptAt  sql.NullTime
+			responseStatus sql.NullInt32
+		)
+		err := rows.Scan(
+			&totalRecords,
+			&delivery.ID,
+			&delivery.CreatedAt,
+			&delivery.WebhookID,
+			&delivery.Event,
+			&delivery.Payload,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.NextAttemptAt,
+			&lastAttemptAt,
+			&responseStatus,
+			&delivery.LastError,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		if lastAttemptAt.Valid {
+			delivery.LastAttemptAt = &lastAttemptAt.Time
+		}
+		if responseStatus.Valid {
+			status := int(responseStatus.Int32)
+			delivery.ResponseStatus = &status
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return deliveries, metadata, nil
+}
+
+func (m WebhookModel) Redeliver(webhookID, deliveryID int64) (*WebhookDelivery, error) {
+	query := `
+        INSERT INTO webhook_deliveries (
+            webhook_id,
+            event,
+            payload
+        )
+        SELECT
+            webhook_id,
+            event,
+            payload
+        FROM
+            webhook_deliveries
+        WHERE
+            id = $1
+            AND webhook_id = $2
+        RETURNING
+            id,
+            created_at,
+            webhook_id,
+            event,
+            payload,
+            status,
+            attempts,
+            next_attempt_at
+    `
+	var delivery WebhookDelivery
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, deliveryID, webhookID).Scan(
+		&delivery.ID,
+		&delivery.CreatedAt,
+		&delivery.WebhookID,
+		&delivery.Event,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &delivery, nil
+}
+
+func (m WebhookModel) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*WebhookDelivery, error) {
+	query := `
+        UPDATE
+            webhook_deliveries
+        SET
+            attempts = attempts + 1,
+            last_attempt_at = now(),
+            next_attempt_at = now() + make_interval(secs => $2)
+        FROM
+            webhooks
+        WHERE
+            webhooks.id = webhook_deliveries.webhook_id
+            AND webhook_deliveries.id IN (
+                SELECT id
+                FROM webhook_deliveries
+                WHERE status = 'pending'
+                AND next_attempt_at <= now()
+                ORDER BY next_attempt_at
+                LIMIT $1
+                FOR UPDATE SKIP LOCKED
+            )
+        RETURNING
+            webhook_deliveries.id,
+            webhook_deliveries.created_at,
+            webhook_deliveries.webhook_id,
+            webhook_deliveries.event,
+            webhook_deliveries.payload,
+            webhook_deliveries.attempts,
+            webhooks.url,
+            webhooks.secret
+    `
+	rows, err := m.DB.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	deliveries := []*WebhookDelivery{}
+	for rows.Next() {
+		delivery := WebhookDelivery{Status: DeliveryStatusPending}
+		err := rows.Scan(
+			&delivery.ID,
+			&delivery.CreatedAt,
+			&delivery.WebhookID,
+			&delivery.Event,
+			&delivery.Payload,
+			&delivery.Attempts,
+			&delivery.URL,
+			&delivery.Secret,
+		)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (m WebhookModel) RecordAttempt(ctx context.Context, delivery *WebhookDelivery, responseStatus int, attemptErr string, retryAfter time.Duration) error {
+	query := `
+        UPDATE
+            webhook_deliveries
+        SET
+            status = $2,
+            response_status = NULLIF($3, 0),
+            last_error = $4,
+            next_attempt_at = now() + make_interval(secs => $5)
+        WHERE
+            id = $1
+    `
+	args := []any{delivery.ID, delivery.Status, responseStatus, attemptErr, retryAfter.Seconds()}
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	return err
+}
@@ -0,0 +1,323 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"greenlight.gustavosantos.net/internal/backoff"
+)
+
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead"
+)
+
+const (
+	defaultMaxAttempts = 5
+	baseBackoff        = 10 * time.Second
+	maxBackoff         = time.Hour
+)
+
+var ErrUnknownKind = errors.New("unknown job kind")
+
+type Job struct {
+	ID          int64
+	CreatedAt   time.Time
+	Kind        string
+	Payload     json.RawMessage
+	Attempts    int
+	MaxAttempts int
+	RunAt       time.Time
+}
+
+type Handler func(ctx context.Context, job *Job) error
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+type Queue struct {
+	db           *sql.DB
+	logger       *slog.Logger
+	handlers     map[string]Handler
+	concurrency  int
+	pollInterval time.Duration
+	lease        time.Duration
+	wg           sync.WaitGroup
+	jobCtx       context.Context
+	cancelJobs   context.CancelFunc
+}
+
+func New(db *sql.DB, logger *slog.Logger, concurrency int, pollInterval, lease time.Duration) *Queue {
+	jobCtx, cancelJobs := context.WithCancel(context.Background())
+	return &Queue{
+		db:           db,
+		logger:       logger,
+		handlers:     make(map[string]Handler),
+		concurrency:  max(concurrency, 1),
+		pollInterval: pollInterval,
+		lease:        lease,
+		jobCtx:       jobCtx,
+		cancelJobs:   cancelJobs,
+	}
+}
+
+func (q *Queue) Register(kind string, handler Handler) {
+	q.handlers[kind] = handler
+}
+
+func Handle[T any](q *Queue, kind string, fn func(ctx context.Context, payload T) error) {
+	q.Register(kind, func(ctx context.Context, job *Job) error {
+		var payload T
+		err := json.Unmarshal(job.Payload, &payload)
+		if err != nil {
+			return Permanent(fmt.Errorf("decode payload: %w", err))
+		}
+		return fn(ctx, payload)
+	})
+}
+
+type enqueueOptions struct {
+	runAt       time.Time
+	maxAttempts int
+}
+
+type EnqueueOption func(*enqueueOptions)
+
+func Delay(d time.Duration) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.runAt = time.Now().Add(d)
+	}
+}
+
+func At(t time.Time) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.runAt = t
+	}
+}
+
+func MaxAttempts(n int) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.maxAttempts = max(n, 1)
+	}
+}
+
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (q *Queue) Enqueue(ctx context.Context, kind string, payload any, opts ...EnqueueOption) (int64, error) {
+	return enqueue(ctx, q.db, kind, payload, opts...)
+}
+
+func (q *Queue) EnqueueTx(ctx context.Context, tx *sql.Tx, kind string, payload any, opts ...EnqueueOption) (int64, error) {
+	return enqueue(ctx, tx, kind, payload, opts...)
+}
+
+func enqueue(ctx context.Context, db queryer, kind string, payload any, opts ...EnqueueOption) (int64, error) {
+	options := enqueueOptions{runAt: time.Now(), maxAttempts: defaultMaxAttempts}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	js, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	query := `
+        INSERT INTO jobs (
+            kind,
+            payload,
+            run_at,
+            max_attempts
+        )
+        VALUES (
+            $1,
+            $2,
+            $3,
+            $4
+        )
+        RETURNING
+            id
+    `
+	var id int64
+	err = db.QueryRowContext(ctx, query, kind, js, options.runAt, options.maxAttempts).Scan(&id)
+	return id, err
+}
+
+func (q *Queue) Start(ctx context.Context) {
+	for i := 0; i < q.concurrency; i++ {
+		q.wg.Add(1)
+		go q.work(ctx)
+	}
+}
+
+func (q *Queue) Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		q.cancelJobs()
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) work(ctx context.Context) {
+	defer q.wg.Done()
+	for ctx.Err() == nil {
+		job, err := q.claim(ctx)
+		switch {
+		case err == nil:
+			q.run(job)
+			continue
+		case errors.Is(err, sql.ErrNoRows):
+		case ctx.Err() == nil:
+			q.logger.Error(err.Error(), "job", "queue")
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(q.pollInterval):
+		}
+	}
+}
+
+func (q *Queue) claim(ctx context.Context) (*Job, error) {
+	query := `
+        UPDATE
+            jobs
+        SET
+            status = 'running',
+            attempts = attempts + 1,
+            locked_until = now() + make_interval(secs => $1)
+        WHERE
+            id = (
+                SELECT id
+                FROM jobs
+                WHERE (status = 'queued' AND run_at <= now())
+                OR (status = 'running' AND locked_until < now())
+                ORDER BY run_at
+                LIMIT 1
+                FOR UPDATE SKIP LOCKED
+            )
+        RETURNING
+            id,
+            created_at,
+            kind,
+            payload,
+            attempts,
+            max_attempts,
+            run_at
+    `
+	var job Job
+	err := q.db.QueryRowContext(ctx, query, q.lease.Seconds()).Scan(
+		&job.ID,
+		&job.CreatedAt,
+		&job.Kind,
+		&job.Payload,
+		&job.Attempts,
+		&job.MaxAttempts,
+		&job.RunAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (q *Queue) run(job *Job) {
+	ctx, cancel := context.WithTimeout(q.jobCtx, q.lease)
+	defer cancel()
+	err := q.execute(ctx, job)
+	if err != nil {
+		q.logger.Error(err.Error(), "job_id", job.ID, "kind", job.Kind, "attempt", job.Attempts)
+	}
+	err = q.finish(job, err)
+	if err != nil {
+		q.logger.Error(err.Error(), "job_id", job.ID, "kind", job.Kind)
+	}
+}
+
+func (q *Queue) execute(ctx context.Context, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	handler, ok := q.handlers[job.Kind]
+	if !ok {
+		return Permanent(fmt.Errorf("%w %q", ErrUnknownKind, job.Kind))
+	}
+	return handler(ctx, job)
+}
+
+func (q *Queue) finish(job *Job, jobErr error) error {
+	status := StatusSucceeded
+	lastError := ""
+	var retryAfter time.Duration
+	if jobErr != nil {
+		lastError = jobErr.Error()
+		var permanent permanentError
+		switch {
+		case errors.As(jobErr, &permanent), job.Attempts >= job.MaxAttempts:
+			status = StatusDead
+		default:
+			status = StatusQueued
+			retryAfter = backoff.Exponential(job.Attempts, baseBackoff, maxBackoff)
+		}
+	}
+	query := `
+        UPDATE
+            jobs
+        SET
+            status = $2,
+            last_error = $3,
+            run_at = CASE WHEN $2 = 'queued' THEN now() + make_interval(secs => $4) ELSE run_at END,
+            finished_at = CASE WHEN $2 = 'queued' THEN NULL ELSE now() END,
+            locked_until = NULL
+        WHERE
+            id = $1
+    `
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := q.db.ExecContext(ctx, query, job.ID, status, lastError, retryAfter.Seconds())
+	return err
+}
+
+func (q *Queue) Purge(ctx context.Context, olderThan time.Duration) (int64, error) {
+	query := `
+        DELETE FROM
+            jobs
+        WHERE
+            status = 'succeeded'
+            AND finished_at < now() - make_interval(secs => $1)
+    `
+	result, err := q.db.ExecContext(ctx, query, olderThan.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -28,6 +28,10 @@ func New(host string, port int, username, password, sender string) Mailer {
 }
 
 func (m Mailer) Send(recipient, templateFile string, data any) error {
+	return m.SendWithHeaders(recipient, templateFile, data, nil)
+}
+
+func (m Mailer) SendWithHeaders(recipient, templateFile string, data any, headers map[string]string) error {
 	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
 	if err != nil {
 		return err
@@ -51,6 +55,9 @@ func (m Mailer) Send(recipient, templateFile string, data any) error {
 	msg.SetHeader("To", recipient)
 	msg.SetHeader("From", m.sender)
 	msg.SetHeader("Subject", subject.String())
+	for name, value := range headers {
+		msg.SetHeader(name, value)
+	}
 	msg.SetBody("text/plain", plainBody.String())
 	msg.AddAlternative("text/html", htmlBody.String())
 	for i := 1; i <= 3; i++ {
@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInvalid(t *testing.T) {
+	specs := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"@every 5m",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1-a * * * *",
+		"1,,2 * * * *",
+	}
+	for _, spec := range specs {
+		t.Run(spec, func(t *testing.T) {
+			_, err := Parse(spec)
+			if err == nil {
+				t.Errorf("Parse(%q) succeeded, want error", spec)
+			}
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	date := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name  string
+		spec  string
+		after time.Time
+		want  time.Time
+	}{
+		{"hourly", "@hourly", date(2024, 1, 1, 10, 15), date(2024, 1, 1, 11, 0)},
+		{"hourly on the hour", "@hourly", date(2024, 1, 1, 10, 0), date(2024, 1, 1, 11, 0)},
+		{"daily", "@daily", date(2024, 1, 1, 10, 15), date(2024, 1, 2, 0, 0)},
+		{"weekly", "@weekly", date(2024, 1, 3, 10, 15), date(2024, 1, 7, 0, 0)},
+		{"monthly", "@monthly", date(2024, 1, 15, 10, 15), date(2024, 2, 1, 0, 0)},
+		{"yearly", "@yearly", date(2024, 3, 1, 0, 0), date(2025, 1, 1, 0, 0)},
+		{"every minute", "* * * * *", date(2024, 12, 31, 23, 59), date(2025, 1, 1, 0, 0)},
+		{"ranges", "0 9-17 * * 1-5", date(2024, 1, 5, 17, 30), date(2024, 1, 8, 9, 0)},
+		{"step", "*/15 * * * *", date(2024, 1, 1, 10, 16), date(2024, 1, 1, 10, 30)},
+		{"step over range", "10-40/10 * * * *", date(2024, 1, 1, 10, 41), date(2024, 1, 1, 11, 10)},
+		{"step from value", "5/20 * * * *", date(2024, 1, 1, 10, 46), date(2024, 1, 1, 11, 5)},
+		{"list", "0 0 1,15 * *", date(2024, 1, 2, 0, 0), date(2024, 1, 15, 0, 0)},
+		{"month", "0 0 * 6 *", date(2024, 1, 2, 0, 0), date(2024, 6, 1, 0, 0)},
+		{"leap day", "0 0 29 2 *", date(2024, 3, 1, 0, 0), date(2028, 2, 29, 0, 0)},
+		{"sunday as 7", "0 0 * * 7", date(2024, 1, 3, 0, 0), date(2024, 1, 7, 0, 0)},
+		{"sunday as 0", "0 0 * * 0", date(2024, 1, 3, 0, 0), date(2024, 1, 7, 0, 0)},
+		{"day of month or day of week", "0 0 13 * 5", date(2024, 1, 1, 0, 0), date(2024, 1, 5, 0, 0)},
+		{"day of month or day of week on the 13th", "0 0 13 * 5", date(2024, 1, 12, 0, 0), date(2024, 1, 13, 0, 0)},
+		{"starred day of week step restricts day of month", "0 0 1 * */2", date(2024, 2, 1, 0, 0), date(2024, 6, 1, 0, 0)},
+		{"starred day of month step restricts day of week", "0 0 */10 * 1", date(2024, 1, 1, 0, 0), date(2024, 3, 11, 0, 0)},
+		{"never", "0 0 30 2 *", date(2024, 1, 1, 0, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if schedule.String() != tt.spec {
+				t.Errorf("got String() %q, want %q", schedule.String(), tt.spec)
+			}
+			got := schedule.Next(tt.after)
+			if !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.after, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+)
+
+type TaskFunc func(ctx context.Context) error
+
+type Run struct {
+	ScheduledAt time.Time `json:"scheduled_at"`
+	StartedAt   time.Time `json:"started_at"`
+	Duration    string    `json:"duration"`
+	Outcome     string    `json:"outcome"`
+	Error       string    `json:"error,omitempty"`
+}
+
+type TaskStatus struct {
+	Name      string    `json:"name"`
+	Schedule  string    `json:"schedule"`
+	Jitter    string    `json:"jitter"`
+	NextRunAt time.Time `json:"next_run_at"`
+	LastRun   *Run      `json:"last_run"`
+}
+
+type task struct {
+	name     string
+	schedule *Schedule
+	jitter   time.Duration
+	timeout  time.Duration
+	fn       TaskFunc
+	lockKey  int64
+
+	mu        sync.Mutex
+	nextRunAt time.Time
+}
+
+type Scheduler struct {
+	db     *sql.DB
+	logger *slog.Logger
+	tasks  []*task
+}
+
+func New(db *sql.DB, logger *slog.Logger) *Scheduler {
+	return &Scheduler{db: db, logger: logger}
+}
+
+func (s *Scheduler) Add(name, spec string, jitter, timeout time.Duration, fn TaskFunc) error {
+	schedule, err := Parse(spec)
+	if err != nil {
+		return err
+	}
+	for _, t := range s.tasks {
+		if t.name == name {
+			return fmt.Errorf("duplicate task name %q", name)
+		}
+	}
+	h := fnv.New64a()
+	h.Write([]byte("scheduler:" + name))
+	s.tasks = append(s.tasks, &task{
+		name:     name,
+		schedule: schedule,
+		jitter:   jitter,
+		timeout:  timeout,
+		fn:       fn,
+		lockKey:  int64(h.Sum64()),
+	})
+	return nil
+}
+
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, t := range s.tasks {
+		wg.Add(1)
+		go func(t *task) {
+			defer wg.Done()
+			s.loop(ctx, t)
+		}(t)
+	}
+	wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, t *task) {
+	for {
+		scheduledAt := t.schedule.Next(time.Now())
+		if scheduledAt.IsZero() {
+			s.logger.Error("schedule never fires", "task", t.name, "schedule", t.schedule.String())
+			return
+		}
+		runAt := scheduledAt
+		if t.jitter > 0 {
+			runAt = runAt.Add(time.Duration(rand.Int63n(int64(t.jitter))))
+		}
+		t.mu.Lock()
+		t.nextRunAt = runAt
+		t.mu.Unlock()
+		timer := time.NewTimer(time.Until(runAt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		err := s.run(ctx, t, scheduledAt)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			s.logger.Error(err.Error(), "task", t.name)
+		}
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, t *task, scheduledAt time.Time) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var locked bool
+	err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, t.lockKey).Scan(&locked)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return nil
+	}
+	defer func() {
+		unlockCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 3*time.Second)
+		defer cancel()
+		_, err := conn.ExecContext(unlockCtx, `SELECT pg_advisory_unlock($1)`, t.lockKey)
+		if err != nil {
+			s.logger.Error(err.Error(), "task", t.name)
+		}
+	}()
+	var alreadyRan bool
+	query := `
+        SELECT EXISTS (
+            SELECT 1
+            FROM scheduler_tasks
+            WHERE name = $1
+            AND scheduled_at >= $2
+        )
+    `
+	err = conn.QueryRowContext(ctx, query, t.name, scheduledAt).Scan(&alreadyRan)
+	if err != nil || alreadyRan {
+		return err
+	}
+	startedAt := time.Now()
+	taskErr := s.execute(ctx, t)
+	duration := time.Since(startedAt)
+	outcome, message := OutcomeSucceeded, ""
+	if taskErr != nil {
+		outcome, message = OutcomeFailed, taskErr.Error()
+		s.logger.Error(message, "task", t.name, "duration", duration.String())
+	} else {
+		s.logger.Info("scheduled task completed", "task", t.name, "duration", duration.String())
+	}
+	query = `
+        INSERT INTO scheduler_tasks (
+            name,
+            scheduled_at,
+            started_at,
+            duration_ms,
+            outcome,
+            error
+        )
+        VALUES (
+            $1,
+            $2,
+            $3,
+            $4,
+            $5,
+            $6
+        )
+        ON CONFLICT (name) DO UPDATE SET
+            scheduled_at = EXCLUDED.scheduled_at,
+            started_at = EXCLUDED.started_at,
+            duration_ms = EXCLUDED.duration_ms,
+            outcome = EXCLUDED.outcome,
+            error = EXCLUDED.error
+    `
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 3*time.Second)
+	defer cancel()
+	_, err = conn.ExecContext(recordCtx, query, t.name, scheduledAt, startedAt, duration.Milliseconds(), outcome, message)
+	return err
+}
+
+func (s *Scheduler) execute(ctx context.Context, t *task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+	return t.fn(ctx)
+}
+
+func (s *Scheduler) Status(ctx context.Context) ([]*TaskStatus, error) {
+	query := `
+        SELECT
+            name,
+            scheduled_at,
+            started_at,
+            duration_ms,
+            outcome,
+            error
+        FROM
+            scheduler_tasks
+    `
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	runs := make(map[string]*Run)
+	for rows.Next() {
+		var (
+			name       string
+			run        Run
+			durationMS int64
+		)
+		err := rows.Scan(&name, &run.ScheduledAt, &run.StartedAt, &durationMS, &run.Outcome, &run.Error)
+		if err != nil {
+			return nil, err
+		}
+		run.Duration = (time.Duration(durationMS) * time.Millisecond).String()
+		runs[name] = &run
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	statuses := make([]*TaskStatus, len(s.tasks))
+	for i, t := range s.tasks {
+		t.mu.Lock()
+		nextRunAt := t.nextRunAt
+		t.mu.Unlock()
+		statuses[i] = &TaskStatus{
+			Name:      t.name,
+			Schedule:  t.schedule.String(),
+			Jitter:    t.jitter.String(),
+			NextRunAt: nextRunAt,
+			LastRun:   runs[t.name],
+		}
+	}
+	return statuses, nil
+}
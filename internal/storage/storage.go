@@ -0,0 +1,14 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrObjectNotFound = errors.New("object not found")
+
+type Storage interface {
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+}
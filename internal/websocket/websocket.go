@@ -0,0 +1,325 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opContinuation = 0
+	OpText         = 1
+	OpBinary       = 2
+	OpClose        = 8
+	OpPing         = 9
+	OpPong         = 10
+)
+
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+)
+
+var (
+	ErrBadHandshake  = errors.New("websocket: bad handshake")
+	ErrClosed        = errors.New("websocket: connection closed")
+	ErrMessageTooBig = errors.New("websocket: message too big")
+	errProtocol      = errors.New("websocket: protocol error")
+)
+
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: closed with code %d %s", e.Code, e.Reason)
+}
+
+type Conn struct {
+	conn         net.Conn
+	br           *bufio.Reader
+	Subprotocol  string
+	ReadLimit    int64
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	writeMu   sync.Mutex
+	closeSent bool
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func Subprotocols(r *http.Request) []string {
+	var protocols []string
+	for _, value := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, part := range strings.Split(value, ",") {
+			if protocol := strings.TrimSpace(part); protocol != "" {
+				protocols = append(protocols, protocol)
+			}
+		}
+	}
+	return protocols
+}
+
+func IsUpgrade(r *http.Request) bool {
+	return headerContains(r.Header, "Connection", "upgrade") && headerContains(r.Header, "Upgrade", "websocket")
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func Upgrade(w http.ResponseWriter, r *http.Request, protocols []string) (*Conn, error) {
+	if r.Method != http.MethodGet || !IsUpgrade(r) || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, ErrBadHandshake
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(decoded) != 16 {
+		return nil, ErrBadHandshake
+	}
+	netConn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, err
+	}
+	if brw.Reader.Buffered() > 0 {
+		netConn.Close()
+		return nil, errProtocol
+	}
+	err = netConn.SetDeadline(time.Time{})
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	c := newConn(netConn, brw.Reader)
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n"
+	for _, offered := range Subprotocols(r) {
+		if slices.Contains(protocols, offered) {
+			c.Subprotocol = offered
+			response += "Sec-WebSocket-Protocol: " + offered + "\r\n"
+			break
+		}
+	}
+	response += "\r\n"
+	netConn.SetWriteDeadline(time.Now().Add(c.WriteTimeout))
+	_, err = io.WriteString(netConn, response)
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func newConn(netConn net.Conn, br *bufio.Reader) *Conn {
+	return &Conn{
+		conn:         netConn,
+		br:           br,
+		ReadLimit:    64 << 10,
+		WriteTimeout: 10 * time.Second,
+	}
+}
+
+func (c *Conn) readFrame() (bool, int, []byte, error) {
+	if c.ReadTimeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.ReadTimeout))
+	}
+	var header [2]byte
+	_, err := io.ReadFull(c.br, header[:])
+	if err != nil {
+		return false, 0, nil, err
+	}
+	fin := header[0]&0x80 != 0
+	op := int(header[0] & 0x0f)
+	if header[0]&0x70 != 0 || header[1]&0x80 == 0 {
+		return false, 0, nil, errProtocol
+	}
+	length := int64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		_, err = io.ReadFull(c.br, ext[:])
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, err = io.ReadFull(c.br, ext[:])
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+	if err != nil {
+		return false, 0, nil, err
+	}
+	if length < 0 || (op >= OpClose && (!fin || length > 125)) {
+		return false, 0, nil, errProtocol
+	}
+	if length > c.ReadLimit {
+		return false, 0, nil, ErrMessageTooBig
+	}
+	var mask [4]byte
+	_, err = io.ReadFull(c.br, mask[:])
+	if err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, length)
+	_, err = io.ReadFull(c.br, payload)
+	if err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	var (
+		op      int
+		message []byte
+	)
+	for {
+		fin, frameOp, payload, err := c.readFrame()
+		switch {
+		case errors.Is(err, errProtocol):
+			c.CloseWith(CloseProtocolError, "")
+			return 0, nil, err
+		case errors.Is(err, ErrMessageTooBig):
+			c.CloseWith(CloseMessageTooBig, "")
+			return 0, nil, err
+		case err != nil:
+			return 0, nil, err
+		}
+		switch frameOp {
+		case OpPing:
+			err = c.writeFrame(OpPong, payload)
+			if err != nil {
+				return 0, nil, err
+			}
+			continue
+		case OpPong:
+			continue
+		case OpClose:
+			closeErr := &CloseError{Code: CloseNormal}
+			if len(payload) >= 2 {
+				closeErr.Code = int(binary.BigEndian.Uint16(payload))
+				closeErr.Reason = string(payload[2:])
+			}
+			c.CloseWith(closeErr.Code, "")
+			return 0, nil, closeErr
+		case opContinuation:
+			if op == 0 {
+				c.CloseWith(CloseProtocolError, "")
+				return 0, nil, errProtocol
+			}
+		case OpText, OpBinary:
+			if op != 0 {
+				c.CloseWith(CloseProtocolError, "")
+				return 0, nil, errProtocol
+			}
+			op = frameOp
+		default:
+			c.CloseWith(CloseProtocolError, "")
+			return 0, nil, errProtocol
+		}
+		message = append(message, payload...)
+		if int64(len(message)) > c.ReadLimit {
+			c.CloseWith(CloseMessageTooBig, "")
+			return 0, nil, ErrMessageTooBig
+		}
+		if fin {
+			if op == OpText && !utf8.Valid(message) {
+				c.CloseWith(CloseInvalidPayload, "")
+				return 0, nil, errProtocol
+			}
+			return op, message, nil
+		}
+	}
+}
+
+func (c *Conn) writeFrame(op int, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrClosed
+	}
+	frame := make([]byte, 0, len(payload)+10)
+	frame = append(frame, 0x80|byte(op))
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	frame = append(frame, payload...)
+	if c.WriteTimeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.WriteTimeout))
+	}
+	if op == OpClose {
+		c.closeSent = true
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+func (c *Conn) WriteMessage(op int, data []byte) error {
+	return c.writeFrame(op, data)
+}
+
+func (c *Conn) WriteJSON(v any) error {
+	js, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(OpText, js)
+}
+
+func (c *Conn) Ping() error {
+	return c.writeFrame(OpPing, nil)
+}
+
+func (c *Conn) CloseWith(code int, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	payload = append(payload, reason...)
+	if len(payload) > 125 {
+		payload = payload[:125]
+	}
+	return c.writeFrame(OpClose, payload)
+}
+
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,418 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testFrame struct {
+	op      int
+	payload []byte
+}
+
+func encodeFrame(fin bool, op int, payload []byte, masked bool) []byte {
+	var b []byte
+	first := byte(op)
+	if fin {
+		first |= 0x80
+	}
+	b = append(b, first)
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) <= 125:
+		b = append(b, maskBit|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		b = append(b, maskBit|126)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(payload)))
+	default:
+		b = append(b, maskBit|127)
+		b = binary.BigEndian.AppendUint64(b, uint64(len(payload)))
+	}
+	if !masked {
+		return append(b, payload...)
+	}
+	mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+	b = append(b, mask[:]...)
+	for i, c := range payload {
+		b = append(b, c^mask[i%4])
+	}
+	return b
+}
+
+func decodeFrames(t *testing.T, b []byte) []testFrame {
+	t.Helper()
+	var frames []testFrame
+	for len(b) > 0 {
+		if len(b) < 2 || b[1]&0x80 != 0 {
+			t.Fatalf("malformed server frame % x", b)
+		}
+		op := int(b[0] & 0x0f)
+		length, header := int(b[1]&0x7f), 2
+		switch length {
+		case 126:
+			length, header = int(binary.BigEndian.Uint16(b[2:])), 4
+		case 127:
+			length, header = int(binary.BigEndian.Uint64(b[2:])), 10
+		}
+		frames = append(frames, testFrame{op: op, payload: b[header : header+length]})
+		b = b[header+length:]
+	}
+	return frames
+}
+
+func closeFrame(code int, reason string) testFrame {
+	return testFrame{op: OpClose, payload: append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...)}
+}
+
+func pipe(t *testing.T, input []byte, readLimit int64, fn func(c *Conn)) []byte {
+	t.Helper()
+	server, client := net.Pipe()
+	c := newConn(server, bufio.NewReader(server))
+	if readLimit > 0 {
+		c.ReadLimit = readLimit
+	}
+	go func() {
+		client.Write(input)
+	}()
+	output := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(client)
+		output <- b
+	}()
+	fn(c)
+	server.Close()
+	return <-output
+}
+
+func TestReadMessage(t *testing.T) {
+	long := bytes.Repeat([]byte("a"), 300)
+	huge := bytes.Repeat([]byte("b"), 70000)
+	tests := []struct {
+		name      string
+		input     [][]byte
+		readLimit int64
+		wantOp    int
+		wantMsg   []byte
+		wantErr   error
+		wantClose int
+		wantOut   []testFrame
+	}{
+		{
+			name:    "masked text",
+			input:   [][]byte{encodeFrame(true, OpText, []byte("hello"), true)},
+			wantOp:  OpText,
+			wantMsg: []byte("hello"),
+		},
+		{
+			name:    "masked binary",
+			input:   [][]byte{encodeFrame(true, OpBinary, []byte{0xff, 0x00}, true)},
+			wantOp:  OpBinary,
+			wantMsg: []byte{0xff, 0x00},
+		},
+		{
+			name:    "empty text",
+			input:   [][]byte{encodeFrame(true, OpText, nil, true)},
+			wantOp:  OpText,
+			wantMsg: nil,
+		},
+		{
+			name:    "unmasked frame",
+			input:   [][]byte{encodeFrame(true, OpText, []byte("hello"), false)},
+			wantErr: errProtocol,
+			wantOut: []testFrame{closeFrame(CloseProtocolError, "")},
+		},
+		{
+			name:    "16-bit extended length",
+			input:   [][]byte{encodeFrame(true, OpText, long, true)},
+			wantOp:  OpText,
+			wantMsg: long,
+		},
+		{
+			name:      "64-bit extended length",
+			input:     [][]byte{encodeFrame(true, OpBinary, huge, true)},
+			readLimit: 1 << 20,
+			wantOp:    OpBinary,
+			wantMsg:   huge,
+		},
+		{
+			name:    "64-bit length with the most significant bit set",
+			input:   [][]byte{{0x81, 0xff, 0x80, 0, 0, 0, 0, 0, 0, 5}},
+			wantErr: errProtocol,
+			wantOut: []testFrame{closeFrame(CloseProtocolError, "")},
+		},
+		{
+			name: "fragmented text",
+			input: [][]byte{
+				encodeFrame(false, OpText, []byte("hel"), true),
+				encodeFrame(false, opContinuation, []byte("l"), true),
+				encodeFrame(true, opContinuation, []byte("o"), true),
+			},
+			wantOp:  OpText,
+			wantMsg: []byte("hello"),
+		},
+		{
+			name: "ping during fragmented text",
+			input: [][]byte{
+				encodeFrame(false, OpText, []byte("hel"), true),
+				encodeFrame(true, OpPing, []byte("are you there"), true),
+				encodeFrame(true, opContinuation, []byte("lo"), true),
+			},
+			wantOp:  OpText,
+			wantMsg: []byte("hello"),
+			wantOut: []testFrame{{op: OpPong, payload: []byte("are you there")}},
+		},
+		{
+			name: "pong is ignored",
+			input: [][]byte{
+				encodeFrame(true, OpPong, []byte("late"), true),
+				encodeFrame(true, OpText, []byte("hello"), true),
+			},
+			wantOp:  OpText,
+			wantMsg: []byte("hello"),
+		},
+		{
+			name: "utf-8 split across fragments",
+			input: [][]byte{
+				encodeFrame(false, OpText, []byte("caf\xc3"), true),
+				encodeFrame(true, opContinuation, []byte("\xa9"), true),
+			},
+			wantOp:  OpText,
+			wantMsg: []byte("café"),
+		},
+		{
+			name:      "oversize frame",
+			input:     [][]byte{encodeFrame(true, OpText, long, true)},
+			readLimit: 100,
+			wantErr:   ErrMessageTooBig,
+			wantOut:   []testFrame{closeFrame(CloseMessageTooBig, "")},
+		},
+		{
+			name: "oversize fragmented message",
+			input: [][]byte{
+				encodeFrame(false, OpText, long[:80], true),
+				encodeFrame(true, opContinuation, long[:80], true),
+			},
+			readLimit: 100,
+			wantErr:   ErrMessageTooBig,
+			wantOut:   []testFrame{closeFrame(CloseMessageTooBig, "")},
+		},
+		{
+			name:    "invalid utf-8",
+			input:   [][]byte{encodeFrame(true, OpText, []byte{0xff, 0xfe}, true)},
+			wantErr: errProtocol,
+			wantOut: []testFrame{closeFrame(CloseInvalidPayload, "")},
+		},
+		{
+			name:    "invalid utf-8 in binary is allowed",
+			input:   [][]byte{encodeFrame(true, OpBinary, []byte{0xff, 0xfe}, true)},
+			wantOp:  OpBinary,
+			wantMsg: []byte{0xff, 0xfe},
+		},
+		{
+			name:    "continuation without a message",
+			input:   [][]byte{encodeFrame(true, opContinuation, []byte("lo"), true)},
+			wantErr: errProtocol,
+			wantOut: []testFrame{closeFrame(CloseProtocolError, "")},
+		},
+		{
+			name: "new message during fragmented message",
+			input: [][]byte{
+				encodeFrame(false, OpText, []byte("hel"), true),
+				encodeFrame(true, OpText, []byte("lo"), true),
+			},
+			wantErr: errProtocol,
+			wantOut: []testFrame{closeFrame(CloseProtocolError, "")},
+		},
+		{
+			name:    "fragmented control frame",
+			input:   [][]byte{encodeFrame(false, OpPing, []byte("ping"), true)},
+			wantErr: errProtocol,
+			wantOut: []testFrame{closeFrame(CloseProtocolError, "")},
+		},
+		{
+			name:    "oversize control frame",
+			input:   [][]byte{encodeFrame(true, OpPing, long[:126], true)},
+			wantErr: errProtocol,
+			wantOut: []testFrame{closeFrame(CloseProtocolError, "")},
+		},
+		{
+			name:    "reserved bits",
+			input:   [][]byte{{0xc1, 0x80, 0, 0, 0, 0}},
+			wantErr: errProtocol,
+			wantOut: []testFrame{closeFrame(CloseProtocolError, "")},
+		},
+		{
+			name:    "unknown opcode",
+			input:   [][]byte{encodeFrame(true, 3, []byte("x"), true)},
+			wantErr: errProtocol,
+			wantOut: []testFrame{closeFrame(CloseProtocolError, "")},
+		},
+		{
+			name:      "close",
+			input:     [][]byte{encodeFrame(true, OpClose, closeFrame(CloseGoingAway, "bye").payload, true)},
+			wantClose: CloseGoingAway,
+			wantOut:   []testFrame{closeFrame(CloseGoingAway, "")},
+		},
+		{
+			name:      "close without code",
+			input:     [][]byte{encodeFrame(true, OpClose, nil, true)},
+			wantClose: CloseNormal,
+			wantOut:   []testFrame{closeFrame(CloseNormal, "")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				op  int
+				msg []byte
+				err error
+			)
+			out := pipe(t, bytes.Join(tt.input, nil), tt.readLimit, func(c *Conn) {
+				op, msg, err = c.ReadMessage()
+			})
+			var closeErr *CloseError
+			switch {
+			case tt.wantClose != 0:
+				if !errors.As(err, &closeErr) || closeErr.Code != tt.wantClose {
+					t.Fatalf("got error %v, want close code %d", err, tt.wantClose)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Fatalf("unexpected error %v", err)
+			default:
+				if op != tt.wantOp || !bytes.Equal(msg, tt.wantMsg) {
+					t.Errorf("got op %d message %q, want op %d message %q", op, msg, tt.wantOp, tt.wantMsg)
+				}
+			}
+			frames := decodeFrames(t, out)
+			if len(frames) != len(tt.wantOut) {
+				t.Fatalf("got %d server frames, want %d", len(frames), len(tt.wantOut))
+			}
+			for i, frame := range frames {
+				if frame.op != tt.wantOut[i].op || !bytes.Equal(frame.payload, tt.wantOut[i].payload) {
+					t.Errorf("got server frame %d op %d payload %q, want op %d payload %q", i, frame.op, frame.payload, tt.wantOut[i].op, tt.wantOut[i].payload)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		op      int
+		payload []byte
+		header  []byte
+	}{
+		{"short", OpText, []byte("hello"), []byte{0x81, 5}},
+		{"16-bit extended length", OpBinary, bytes.Repeat([]byte("a"), 300), []byte{0x82, 126, 0x01, 0x2c}},
+		{"64-bit extended length", OpBinary, bytes.Repeat([]byte("a"), 70000), []byte{0x82, 127, 0, 0, 0, 0, 0, 0x01, 0x11, 0x70}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			out := pipe(t, nil, 0, func(c *Conn) {
+				err = c.WriteMessage(tt.op, tt.payload)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasPrefix(out, tt.header) || !bytes.Equal(out[len(tt.header):], tt.payload) {
+				t.Errorf("got frame header % x, want % x", out[:min(len(out), len(tt.header))], tt.header)
+			}
+		})
+	}
+}
+
+func TestWriteAfterClose(t *testing.T) {
+	var closeErr, writeErr error
+	out := pipe(t, nil, 0, func(c *Conn) {
+		closeErr = c.CloseWith(CloseNormal, strings.Repeat("x", 200))
+		writeErr = c.WriteMessage(OpText, []byte("too late"))
+	})
+	if closeErr != nil {
+		t.Fatal(closeErr)
+	}
+	if !errors.Is(writeErr, ErrClosed) {
+		t.Errorf("got error %v, want ErrClosed", writeErr)
+	}
+	frames := decodeFrames(t, out)
+	if len(frames) != 1 || frames[0].op != OpClose || len(frames[0].payload) != 125 {
+		t.Errorf("got frames %v, want a single close frame truncated to 125 bytes", frames)
+	}
+}
+
+func TestUpgradeSubprotocol(t *testing.T) {
+	tests := []struct {
+		name    string
+		offered []string
+		want    string
+	}{
+		{"none offered", nil, ""},
+		{"supported", []string{"chat.v1", "bearer.abc.def"}, "chat.v1"},
+		{"token is never selected", []string{"bearer.abc.def", "chat.v1"}, "chat.v1"},
+		{"unsupported", []string{"bearer.abc.def", "other"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected := make(chan string, 1)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c, err := Upgrade(w, r, []string{"chat.v1"})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				selected <- c.Subprotocol
+				c.Close()
+			}))
+			defer srv.Close()
+			conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", "websocket")
+			req.Header.Set("Sec-WebSocket-Version", "13")
+			req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+			if len(tt.offered) > 0 {
+				req.Header.Set("Sec-WebSocket-Protocol", strings.Join(tt.offered, ", "))
+			}
+			err = req.Write(conn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusSwitchingProtocols {
+				t.Fatalf("got status %d", resp.StatusCode)
+			}
+			if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+				t.Errorf("got Sec-WebSocket-Accept %q", got)
+			}
+			if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != tt.want {
+				t.Errorf("got Sec-WebSocket-Protocol %q, want %q", got, tt.want)
+			}
+			if got := <-selected; got != tt.want {
+				t.Errorf("got Subprotocol %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
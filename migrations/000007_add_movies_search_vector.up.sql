@@ -0,0 +1,7 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', title), 'A') ||
+    setweight(to_tsvector('english', title), 'B') ||
+    setweight(to_tsvector('portuguese', title), 'B') ||
+    setweight(to_tsvector('spanish', title), 'B')
+) STORED;
+CREATE INDEX IF NOT EXISTS movies_search_vector_idx ON movies USING GIN (search_vector);
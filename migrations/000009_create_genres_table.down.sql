@@ -0,0 +1,2 @@
+DELETE FROM permissions WHERE code = 'admin';
+DROP TABLE IF EXISTS genres;
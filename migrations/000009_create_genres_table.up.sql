@@ -0,0 +1,83 @@
+CREATE TABLE IF NOT EXISTS genres (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    slug text UNIQUE NOT NULL,
+    name text NOT NULL,
+    aliases text[] NOT NULL DEFAULT '{}',
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS genres_aliases_idx ON genres USING GIN (aliases);
+
+INSERT INTO genres (slug, name, aliases)
+VALUES
+    ('science-fiction', 'Science Fiction', '{sci-fi,scifi,sf}'),
+    ('documentary', 'Documentary', '{documentaries,doc,docs}'),
+    ('animation', 'Animation', '{animated,cartoon}'),
+    ('romance', 'Romance', '{romantic}'),
+    ('thriller', 'Thriller', '{thrillers,suspense}')
+ON CONFLICT (slug) DO NOTHING;
+
+INSERT INTO genres (slug, name)
+SELECT DISTINCT ON (slug)
+    slug,
+    name
+FROM (
+    SELECT
+        trim(both '-' FROM lower(regexp_replace(genre, '[^[:alnum:]]+', '-', 'g'))) AS slug,
+        trim(genre) AS name
+    FROM
+        movies,
+        unnest(movies.genres) AS genre
+) AS normalized
+WHERE
+    slug <> ''
+    AND NOT EXISTS (
+        SELECT 1
+        FROM genres
+        WHERE genres.aliases && ARRAY[lower(normalized.name), normalized.slug]
+    )
+ORDER BY
+    slug,
+    name
+ON CONFLICT (slug) DO NOTHING;
+
+UPDATE movies
+SET
+    genres = normalized.genres
+FROM (
+    SELECT
+        id,
+        array_agg(slug ORDER BY position) AS genres
+    FROM (
+        SELECT
+            movies.id,
+            COALESCE(aliased.slug, normalized.slug) AS slug,
+            min(genre.position) AS position
+        FROM
+            movies
+            CROSS JOIN LATERAL unnest(movies.genres) WITH ORDINALITY AS genre(name, position)
+            CROSS JOIN LATERAL (
+                SELECT trim(both '-' FROM lower(regexp_replace(genre.name, '[^[:alnum:]]+', '-', 'g'))) AS slug
+            ) AS normalized
+            LEFT JOIN LATERAL (
+                SELECT genres.slug
+                FROM genres
+                WHERE genres.aliases && ARRAY[lower(trim(genre.name)), normalized.slug]
+                LIMIT 1
+            ) AS aliased ON true
+        GROUP BY
+            movies.id,
+            COALESCE(aliased.slug, normalized.slug)
+    ) AS slugs
+    WHERE
+        slug <> ''
+    GROUP BY
+        id
+) AS normalized
+WHERE
+    movies.id = normalized.id;
+
+INSERT INTO permissions (code)
+VALUES
+    ('admin');
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS movies_tmdb_id_idx;
+DROP INDEX IF EXISTS movies_imdb_id_idx;
+
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS movies_tmdb_id_check;
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS movies_imdb_id_check;
+
+ALTER TABLE movies DROP COLUMN IF EXISTS tmdb_id;
+ALTER TABLE movies DROP COLUMN IF EXISTS imdb_id;
+ALTER TABLE movies DROP COLUMN IF EXISTS releases;
+ALTER TABLE movies DROP COLUMN IF EXISTS original_language;
+ALTER TABLE movies DROP COLUMN IF EXISTS overview;
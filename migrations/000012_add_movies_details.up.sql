@@ -0,0 +1,11 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS overview text NOT NULL DEFAULT '';
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS original_language text NOT NULL DEFAULT '';
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS releases jsonb NOT NULL DEFAULT '{}';
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS imdb_id text;
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS tmdb_id bigint;
+
+ALTER TABLE movies ADD CONSTRAINT movies_imdb_id_check CHECK (imdb_id ~ '^tt[0-9]{7,10}$');
+ALTER TABLE movies ADD CONSTRAINT movies_tmdb_id_check CHECK (tmdb_id > 0);
+
+CREATE UNIQUE INDEX IF NOT EXISTS movies_imdb_id_idx ON movies (imdb_id);
+CREATE UNIQUE INDEX IF NOT EXISTS movies_tmdb_id_idx ON movies (tmdb_id);
@@ -0,0 +1,4 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW();
+UPDATE movies SET updated_at = created_at;
+
+CREATE INDEX IF NOT EXISTS movies_updated_at_idx ON movies (updated_at DESC, id DESC);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS movie_popularity;
+DROP TABLE IF EXISTS movie_views;
+
+DROP INDEX IF EXISTS movies_created_at_idx;
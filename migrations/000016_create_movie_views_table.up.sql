@@ -0,0 +1,20 @@
+CREATE INDEX IF NOT EXISTS movies_created_at_idx ON movies (created_at DESC, id DESC);
+
+CREATE TABLE IF NOT EXISTS movie_views (
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    bucket timestamp(0) with time zone NOT NULL,
+    views bigint NOT NULL,
+    PRIMARY KEY (movie_id, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS movie_views_bucket_idx ON movie_views (bucket);
+
+CREATE TABLE IF NOT EXISTS movie_popularity (
+    time_window text NOT NULL,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    score double precision NOT NULL,
+    computed_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (time_window, movie_id)
+);
+
+CREATE INDEX IF NOT EXISTS movie_popularity_score_idx ON movie_popularity (time_window, score DESC);
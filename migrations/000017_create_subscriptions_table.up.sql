@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS subscriptions (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    kind text NOT NULL CHECK (kind IN ('genre')),
+    value text NOT NULL,
+    UNIQUE (user_id, kind, value)
+);
+
+CREATE INDEX IF NOT EXISTS subscriptions_kind_value_idx ON subscriptions (kind, value);
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id bigint PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    digest_enabled boolean NOT NULL DEFAULT true,
+    last_digest_at timestamp(0) with time zone
+);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    reason text NOT NULL,
+    digested_at timestamp(0) with time zone,
+    UNIQUE (user_id, movie_id)
+);
+
+CREATE INDEX IF NOT EXISTS notifications_pending_idx ON notifications (user_id) WHERE digested_at IS NULL;
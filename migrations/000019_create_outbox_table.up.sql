@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    topic text NOT NULL,
+    payload jsonb NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    published_at timestamp(0) with time zone,
+    last_error text NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS outbox_consumers (
+    outbox_id bigint NOT NULL REFERENCES outbox ON DELETE CASCADE,
+    consumer text NOT NULL,
+    consumed_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (outbox_id, consumer)
+);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS scheduler_tasks (
+    name text PRIMARY KEY,
+    scheduled_at timestamp with time zone NOT NULL,
+    started_at timestamp with time zone NOT NULL,
+    duration_ms bigint NOT NULL DEFAULT 0,
+    outcome text NOT NULL,
+    error text NOT NULL DEFAULT ''
+);
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS movie_events (
+    id bigserial PRIMARY KEY,
+    xid xid8 NOT NULL DEFAULT pg_current_xact_id(),
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    movie_id bigint NOT NULL,
+    operation text NOT NULL,
+    version integer NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS movie_events_xid_id_idx ON movie_events (xid, id);
+CREATE INDEX IF NOT EXISTS movie_events_created_at_idx ON movie_events (created_at);
+
+CREATE OR REPLACE FUNCTION record_movie_event() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO movie_events (movie_id, operation, version)
+        VALUES (OLD.id, 'deleted', OLD.version);
+    ELSE
+        INSERT INTO movie_events (movie_id, operation, version)
+        VALUES (NEW.id, CASE TG_OP WHEN 'INSERT' THEN 'created' ELSE 'updated' END, NEW.version);
+    END IF;
+    PERFORM pg_notify('movie_events', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER movies_record_event
+AFTER INSERT OR UPDATE OR DELETE ON movies
+FOR EACH ROW EXECUTE FUNCTION record_movie_event();